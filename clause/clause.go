@@ -1,5 +1,8 @@
 package clause
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
 //子句
 //一个Clause就是一条SQL语句的各行的集合
@@ -38,6 +41,18 @@ func (c *Clause) Set(name Type, vars ...interface{}) {
 	c.sqlVars[name] = vars
 }
 
+//在已有的WHERE分句上追加一个AND条件，原条件会被括号包住以免改变优先级。
+//如果还没有WHERE分句，则等同于Set(WHERE, desc, vars...)
+func (c *Clause) AndWhere(desc string, vars ...interface{}) {
+	old, ok := c.sql[WHERE]
+	if !ok {
+		c.Set(WHERE, append([]interface{}{desc}, vars...)...)
+		return
+	}
+	c.sql[WHERE] = fmt.Sprintf("WHERE (%s) AND %s", strings.TrimPrefix(old, "WHERE "), desc)
+	c.sqlVars[WHERE] = append(c.sqlVars[WHERE], vars...)
+}
+
 //把分句合并起来
 func (c *Clause) Build(orders ...Type) (string, []interface{}) {
 	var sqls []string
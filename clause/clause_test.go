@@ -26,3 +26,17 @@ func TestClause_Build(t *testing.T) {
 		testSelect(t)
 	})
 }
+
+func TestClause_AndWhere(t *testing.T) {
+	var clause Clause
+	clause.Set(UPDATE, "User", map[string]interface{}{"Name": "Tom", "Age": 18}, "Version")
+	clause.Set(WHERE, "Age > ? OR Age < ?", 60, 10)
+	clause.AndWhere("Version = ?", 3)
+	sql, vars := clause.Build(UPDATE, WHERE)
+	if sql != "UPDATE User SET Age = ?, Name = ?, Version = Version + 1 WHERE (Age > ? OR Age < ?) AND Version = ?" {
+		t.Fatal("failed to build SQL", sql)
+	}
+	if !reflect.DeepEqual(vars, []interface{}{18, "Tom", 60, 10, 3}) {
+		t.Fatal("failed to build SQLVars", vars)
+	}
+}
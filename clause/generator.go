@@ -1,6 +1,7 @@
 package clause
 import (
 	"fmt"
+	"sort"
 	"strings"
 )
 //生成器
@@ -86,14 +87,26 @@ func _orderBy(values ...interface{}) (string, []interface{}) {
 	return fmt.Sprintf("ORDER BY %s", values[0]), []interface{}{}
 }
 
+//UPDATE $tableName SET $k1 = ?, $k2 = ?
+//可选的第三个参数是乐观锁的版本号列名，传入时追加 "$version = $version + 1"。
+//列按名字排序，保证同样的输入生成同样的SQL语句。
 func _update(values ...interface{}) (string, []interface{}) {
 	tableName := values[0]
 	m := values[1].(map[string]interface{})
+	names := make([]string, 0, len(m))
+	for k := range m {
+		names = append(names, k)
+	}
+	sort.Strings(names)
 	var keys []string
 	var vars []interface{}
-	for k, v := range m {
+	for _, k := range names {
 		keys = append(keys, k+" = ?")
-		vars = append(vars, v)
+		vars = append(vars, m[k])
+	}
+	if len(values) > 2 {
+		version := values[2].(string)
+		keys = append(keys, fmt.Sprintf("%s = %s + 1", version, version))
 	}
 	return fmt.Sprintf("UPDATE %s SET %s", tableName, strings.Join(keys, ", ")), vars
 }
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"myorm/dialect"
+	"myorm/erd"
+)
+
+var erdCommand = &command{
+	usage: "erd [-format mermaid|dot] [-o file]  draw an entity-relationship diagram of all tables",
+	run:   runERD,
+}
+
+func runERD(db *database, args []string) error {
+	flags := flag.NewFlagSet("erd", flag.ExitOnError)
+	format := flags.String("format", "mermaid", "mermaid or dot (Graphviz)")
+	output := flags.String("o", "", "output file, stdout if empty")
+	_ = flags.Parse(args)
+
+	render, ok := map[string]func(tables []*dialect.TableInfo) string{"mermaid": erd.Mermaid, "dot": erd.DOT}[*format]
+	if !ok {
+		return fmt.Errorf("unknown format %s", *format)
+	}
+	engine, err := db.openExisting()
+	if err != nil {
+		return err
+	}
+	tables, err := erd.Inspect(engine)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err = fmt.Fprint(out, render(tables))
+		return err
+	}
+	return ioutil.WriteFile(*output, []byte(render(tables)), 0644)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"myorm/gen"
+	"strings"
+)
+
+var genCommand = &command{
+	usage: "gen [-pkg models] [-tables a,b] [-naming camel|exported] [-o file]  generate Go structs from tables",
+	run:   runGen,
+}
+
+var namings = map[string]gen.Naming{"camel": gen.CamelCase, "exported": gen.Exported}
+
+func runGen(db *database, args []string) error {
+	flags := flag.NewFlagSet("gen", flag.ExitOnError)
+	pkg := flags.String("pkg", "models", "package name")
+	tables := flags.String("tables", "", "comma separated tables, all tables if empty")
+	naming := flags.String("naming", "camel", "naming of structs and fields: camel (user_id → UserID) or exported (user_id → User_id)")
+	output := flags.String("o", "", "output file, stdout if empty")
+	_ = flags.Parse(args)
+
+	opts := gen.Options{Package: *pkg}
+	if *tables != "" {
+		opts.Tables = strings.Split(*tables, ",")
+	}
+	var ok bool
+	if opts.TableName, ok = namings[*naming]; !ok {
+		return fmt.Errorf("unknown naming %s", *naming)
+	}
+	opts.ColumnName = opts.TableName
+	engine, err := db.openExisting()
+	if err != nil {
+		return err
+	}
+	src, err := gen.Generate(engine, opts)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err = out.Write(src)
+		return err
+	}
+	return ioutil.WriteFile(*output, src, 0644)
+}
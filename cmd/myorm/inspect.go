@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"myorm/dialect"
+	"strconv"
+	"strings"
+)
+
+var inspectCommand = &command{
+	usage: "inspect <table>  print columns, indexes and foreign keys of a table",
+	run:   runInspect,
+}
+
+var schemaCommand = &command{
+	usage: "schema dump  print CREATE statements of all tables",
+	run:   runSchema,
+}
+
+func runInspect(db *database, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: myorm inspect <table>")
+	}
+	engine, err := db.openExisting()
+	if err != nil {
+		return err
+	}
+	info, err := engine.Inspect(args[0])
+	if err != nil {
+		return err
+	}
+	printTableInfo(out, info)
+	return nil
+}
+
+func runSchema(db *database, args []string) error {
+	if len(args) != 1 || args[0] != "dump" {
+		return fmt.Errorf("usage: myorm schema dump")
+	}
+	engine, err := db.openExisting()
+	if err != nil {
+		return err
+	}
+	stmts, err := engine.DumpSchema()
+	if err != nil {
+		return err
+	}
+	for _, stmt := range stmts {
+		fmt.Fprintln(out, stmt)
+	}
+	return nil
+}
+
+//依次打印列、索引和外键，没有索引或外键时省略
+func printTableInfo(w io.Writer, info *dialect.TableInfo) {
+	fmt.Fprintf(w, "Table: %s\n", info.Name)
+	var rows [][]string
+	for _, col := range info.Columns {
+		dflt := "NULL"
+		if col.Default != nil {
+			dflt = *col.Default
+		}
+		rows = append(rows, []string{col.Name, col.Type, strconv.FormatBool(!col.NotNull), dflt, strconv.FormatBool(col.PrimaryKey)})
+	}
+	printTable(w, []string{"Column", "Type", "Nullable", "Default", "Primary"}, rows)
+
+	if len(info.Indexes) > 0 {
+		rows = nil
+		for _, idx := range info.Indexes {
+			var kind []string
+			for _, k := range []struct {
+				name string
+				ok   bool
+			}{{"primary", idx.Primary}, {"unique", idx.Unique}, {"constraint", idx.Constraint}, {"partial", idx.Partial}} {
+				if k.ok {
+					kind = append(kind, k.name)
+				}
+			}
+			rows = append(rows, []string{idx.Name, strings.Join(idx.Columns, ", "), strings.Join(kind, " ")})
+		}
+		fmt.Fprintln(w, "Indexes:")
+		printTable(w, []string{"Index", "Columns", "Kind"}, rows)
+	}
+
+	if len(info.ForeignKeys) > 0 {
+		rows = nil
+		for _, fk := range info.ForeignKeys {
+			rows = append(rows, []string{fk.Name, strings.Join(fk.Columns, ", "),
+				fmt.Sprintf("%s(%s)", fk.RefTable, strings.Join(fk.RefColumns, ", ")), fk.OnDelete, fk.OnUpdate})
+		}
+		fmt.Fprintln(w, "Foreign keys:")
+		printTable(w, []string{"Name", "Columns", "References", "On delete", "On update"}, rows)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"myorm"
+	"myorm/log"
+	"os"
+	"strings"
+)
+
+//myorm命令行工具，不用写Go代码就可以迁移、查看和查询数据库。
+//用法：myorm [-driver 驱动] [-dsn 数据源] [-v] <命令> [参数]
+//driver、dsn也可以用环境变量MYORM_DRIVER、MYORM_DSN设置，命令行参数优先
+
+// command 一个子命令
+type command struct {
+	usage string
+	run   func(db *database, args []string) error
+}
+
+//按帮助中的顺序排列
+var commandNames = []string{"migrate", "inspect", "schema", "sql", "gen", "erd"}
+
+var commands = map[string]*command{
+	"migrate": migrateCommand,
+	"inspect": inspectCommand,
+	"schema":  schemaCommand,
+	"sql":     sqlCommand,
+	"gen":     genCommand,
+	"erd":     erdCommand,
+}
+
+//命令的输出，测试时替换
+var out io.Writer = os.Stdout
+
+func main() {
+	flags := flag.NewFlagSet("myorm", flag.ExitOnError)
+	driver := flags.String("driver", env("MYORM_DRIVER", "sqlite3"), "database driver, env MYORM_DRIVER")
+	dsn := flags.String("dsn", os.Getenv("MYORM_DSN"), "data source name, env MYORM_DSN")
+	verbose := flags.Bool("v", false, "print logs and SQL statements of myorm")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: myorm [flags] <command> [args]\n\ncommands:")
+		for _, name := range commandNames {
+			fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+		}
+		fmt.Fprintln(os.Stderr, "\nflags:")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(os.Args[1:])
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		flags.Usage()
+		os.Exit(2)
+	}
+	//日志默认写到标准输出，会混进生成的代码和查询结果中
+	if *verbose {
+		log.SetLevel(log.DebugLevel)
+	} else {
+		log.SetLevel(log.Disabled)
+	}
+	db := &database{driver: *driver, dsn: *dsn}
+	err := cmd.run(db, flags.Args()[1:])
+	db.close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "myorm:", err)
+		os.Exit(1)
+	}
+}
+
+// database 命令用到数据库时才连接，如migrate create不需要数据库
+type database struct {
+	driver, dsn string
+	engine      *myorm.Engine
+}
+
+func (db *database) open() (*myorm.Engine, error) {
+	if db.engine != nil {
+		return db.engine, nil
+	}
+	if db.dsn == "" {
+		return nil, fmt.Errorf("no data source, use -dsn or MYORM_DSN")
+	}
+	engine, err := myorm.NewEngine(db.driver, db.dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.engine = engine
+	return engine, nil
+}
+
+//只读取已有的数据库：SQLite的数据库文件不存在时报错，而不是建立一个空的数据库
+func (db *database) openExisting() (*myorm.Engine, error) {
+	if db.driver == "sqlite3" && db.dsn != "" {
+		file := strings.TrimPrefix(db.dsn, "file:")
+		if i := strings.Index(file, "?"); i >= 0 {
+			file = file[:i]
+		}
+		if _, err := os.Stat(file); file != ":memory:" && err != nil {
+			return nil, err
+		}
+	}
+	return db.open()
+}
+
+func (db *database) close() {
+	if db.engine != nil {
+		db.engine.Close()
+	}
+}
+
+func env(key, value string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return value
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+//执行一个命令，返回它的输出
+func runCommand(t *testing.T, db *database, name string, args ...string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	out = &buf
+	if err := commands[name].run(db, args); err != nil {
+		t.Fatal(name, args, err)
+	}
+	return buf.String()
+}
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+	migrations := filepath.Join(dir, "migrations")
+	if err := createMigration(migrations, "create_user", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	up := filepath.Join(migrations, "20240102030405_create_user.up.sql")
+	_ = ioutil.WriteFile(up, []byte("CREATE TABLE User (ID integer PRIMARY KEY, Name text NOT NULL);"), 0644)
+
+	db := &database{driver: "sqlite3", dsn: filepath.Join(dir, "app.db")}
+	defer db.close()
+	if _, err := db.openExisting(); err == nil {
+		t.Fatal("openExisting should fail for missing database file")
+	}
+	if got := runCommand(t, db, "migrate", "-dir", migrations, "up"); !strings.Contains(got, "| 20240102030405 | create_user | applied |") {
+		t.Fatal("failed to apply migration", got)
+	}
+	if got := runCommand(t, db, "sql", "INSERT INTO User (Name) VALUES (?)", "Tom"); got != "1 rows affected\n" {
+		t.Fatal("failed to execute statement", got)
+	}
+	want := `+----+------+------+
+| ID | Name | Note |
++----+------+------+
+| 1  | Tom  | NULL |
++----+------+------+
+1 rows
+`
+	if got := runCommand(t, db, "sql", "SELECT *, NULL AS Note FROM User"); got != want {
+		t.Fatalf("unexpected query result:\n%s", got)
+	}
+	if got := runCommand(t, db, "inspect", "User"); !strings.Contains(got, "| Name   | text    | false    | NULL    | false   |") {
+		t.Fatalf("unexpected table info:\n%s", got)
+	}
+	if got := runCommand(t, db, "schema", "dump"); !strings.Contains(got, "CREATE TABLE User (ID integer PRIMARY KEY, Name text NOT NULL);\n") {
+		t.Fatalf("unexpected schema dump:\n%s", got)
+	}
+	if got := runCommand(t, db, "erd", "-format", "dot"); !strings.Contains(got, `"User" [label=<`) {
+		t.Fatalf("unexpected diagram:\n%s", got)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"myorm/migration"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var migrateCommand = &command{
+	usage: "migrate [-dir migrations] [-table schema_migrations] up | down [n] | status | create <name>  run versioned migrations",
+	run:   runMigrate,
+}
+
+var nameRegexp = regexp.MustCompile(`^\w+$`)
+
+func runMigrate(db *database, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dir := flags.String("dir", env("MYORM_MIGRATIONS", "migrations"), "directory of migration files, env MYORM_MIGRATIONS")
+	table := flags.String("table", migration.DefaultTable, "history table")
+	_ = flags.Parse(args)
+	args = flags.Args()
+	if len(args) == 0 {
+		return fmt.Errorf("usage: myorm migrate up | down [n] | status | create <name>")
+	}
+	if args[0] == "create" {
+		if len(args) != 2 || !nameRegexp.MatchString(args[1]) {
+			return fmt.Errorf("usage: myorm migrate create <name>, name can only contain letters, digits and _")
+		}
+		return createMigration(*dir, args[1], time.Now().UTC())
+	}
+
+	engine, err := db.open()
+	if err != nil {
+		return err
+	}
+	m := migration.New(engine).WithTable(*table)
+	if err := m.LoadFS(os.DirFS(*dir), "."); err != nil {
+		return err
+	}
+	switch args[0] {
+	case "up":
+		if err := m.Up(); err != nil {
+			return err
+		}
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil {
+				return err
+			}
+		}
+		if err := m.Down(n); err != nil {
+			return err
+		}
+	case "status":
+	default:
+		return fmt.Errorf("unknown migrate command %s", args[0])
+	}
+	return printStatus(m)
+}
+
+func printStatus(m *migration.Migrator) error {
+	statuses, err := m.Status()
+	if err != nil {
+		return err
+	}
+	var rows [][]string
+	for _, st := range statuses {
+		state, appliedAt := "pending", ""
+		switch {
+		case st.Missing:
+			state = "missing"
+		case st.Modified:
+			state = "modified"
+		case st.Applied:
+			state = "applied"
+		}
+		if st.Applied {
+			appliedAt = st.AppliedAt.Format(time.RFC3339)
+		}
+		rows = append(rows, []string{strconv.FormatInt(st.Version, 10), st.Name, state, appliedAt})
+	}
+	printTable(out, []string{"Version", "Name", "State", "Applied at"}, rows)
+	return nil
+}
+
+//用当前时间作为版本号，建立一对空的迁移文件
+func createMigration(dir, name string, now time.Time) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	version := now.Format("20060102150405")
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%s_%s.%s.sql", version, name, direction))
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%s already exists", path)
+		}
+		content := fmt.Sprintf("-- %s %s\n", name, direction)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "created", path)
+	}
+	return nil
+}
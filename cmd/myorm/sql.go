@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+var sqlCommand = &command{
+	usage: "sql <statement> [args...]  execute a statement and print the result",
+	run:   runSQL,
+}
+
+//返回结果集的语句，其余语句用Exec执行并打印影响的行数
+var queryRegexp = regexp.MustCompile(`(?is)^\s*(SELECT|WITH|PRAGMA|SHOW|EXPLAIN|DESCRIBE|DESC|VALUES)\b|\bRETURNING\b`)
+
+func runSQL(db *database, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: myorm sql <statement> [args...]")
+	}
+	engine, err := db.open()
+	if err != nil {
+		return err
+	}
+	vars := make([]interface{}, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		vars = append(vars, arg)
+	}
+	s := engine.NewSession().Raw(args[0], vars...)
+	if !queryRegexp.MatchString(args[0]) {
+		result, err := s.Exec()
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%d rows affected\n", affected)
+		return nil
+	}
+	rows, err := s.QueryRows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	var table [][]string
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		row := make([]string, len(columns))
+		for i, v := range values {
+			row[i] = formatValue(v)
+		}
+		table = append(table, row)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	printTable(out, columns, table)
+	fmt.Fprintf(out, "%d rows\n", len(table))
+	return nil
+}
+
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+//像数据库客户端一样打印带边框的表格：
+//+----+------+
+//| ID | Name |
+//+----+------+
+//| 1  | Tom  |
+//+----+------+
+func printTable(w io.Writer, header []string, rows [][]string) {
+	widths := make([]int, len(header))
+	for _, row := range append([][]string{header}, rows...) {
+		for i, cell := range row {
+			if n := width(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	border := "+"
+	for _, width := range widths {
+		border += strings.Repeat("-", width+2) + "+"
+	}
+	line := func(row []string) {
+		fmt.Fprint(w, "|")
+		for i, cell := range row {
+			fmt.Fprintf(w, " %s%s |", cell, strings.Repeat(" ", widths[i]-width(cell)))
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, border)
+	line(header)
+	fmt.Fprintln(w, border)
+	for _, row := range rows {
+		line(row)
+	}
+	if len(rows) > 0 {
+		fmt.Fprintln(w, border)
+	}
+}
+
+//显示宽度：中日韩文字和全角字符占两列
+func width(s string) int {
+	n := 0
+	for _, r := range s {
+		n++
+		if unicode.In(r, unicode.Han, unicode.Hangul, unicode.Hiragana, unicode.Katakana) || (r >= 0xFF01 && r <= 0xFF60) || (r >= 0x3000 && r <= 0x303F) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,47 @@
+package myorm
+
+import (
+	"myorm/dialect"
+	"myorm/session"
+	"strings"
+)
+
+//不连接数据库，按方言d生成建立models对应的表和索引的语句，与在空数据库上执行Migrate(models...)相同：
+//被引用的表在前，每个表之后是它的索引，循环引用的外键（SQLite除外）在最后用ALTER TABLE添加。
+//同样的模型总是得到同样的语句，可以把结果保存到代码仓库中，在代码评审时比较表结构的变化
+func DDL(d dialect.Dialect, models ...interface{}) ([]string, error) {
+	m := &migrator{s: session.New(nil, d), offline: true}
+	m.rebuilder, _ = d.(dialect.TableRebuilder)
+	return m.planAll(models)
+}
+
+//按引擎的方言生成建表语句，见DDL
+func (engine *Engine) DDL(models ...interface{}) ([]string, error) {
+	return DDL(engine.dialectSQL, models...)
+}
+
+//导出数据库中全部表（按表名排序）的建立语句，包括索引，SQLite还包括触发器。
+//语句由数据库给出：SQLite为建表时的原始语句，MySQL为SHOW CREATE TABLE的结果，PostgreSQL由系统目录还原。
+//MySQL、PostgreSQL的外键写成ALTER TABLE放在最后，结果可以在空数据库上按顺序执行
+func (engine *Engine) DumpSchema() ([]string, error) {
+	s := engine.Session()
+	tables, err := s.Tables()
+	if err != nil {
+		return nil, err
+	}
+	var stmts, alters []string
+	for _, table := range tables {
+		tableStmts, err := s.DumpTable(table)
+		if err != nil {
+			return nil, err
+		}
+		for _, stmt := range tableStmts {
+			if strings.HasPrefix(stmt, "ALTER TABLE ") {
+				alters = append(alters, stmt)
+			} else {
+				stmts = append(stmts, stmt)
+			}
+		}
+	}
+	return append(stmts, alters...), nil
+}
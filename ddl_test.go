@@ -0,0 +1,176 @@
+package myorm
+
+import (
+	"myorm/dialect"
+	"myorm/internal/fakedb"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type Customer struct {
+	ID    int64  `myorm:"PRIMARY KEY"`
+	Email string `myorm:"NOT NULL;uniqueIndex"`
+}
+
+type Invoice struct {
+	ID         int64 `myorm:"PRIMARY KEY"`
+	CustomerID int64 `myorm:"references:Customer(ID);constraint:OnDelete:CASCADE;index"`
+	Total      float64
+}
+
+func TestDDL(t *testing.T) {
+	pg, _ := dialect.GetDialect("postgres")
+	stmts, err := DDL(pg, &Invoice{}, &Customer{}, &Shelf{}, &Book{}, &Author{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`CREATE TABLE "Customer" ("ID" bigint PRIMARY KEY,"Email" text NOT NULL);`,
+		`CREATE UNIQUE INDEX "idx_Customer_Email" ON "Customer" ("Email");`,
+		`CREATE TABLE "Invoice" ("ID" bigint PRIMARY KEY,"CustomerID" bigint ,"Total" double precision ,CONSTRAINT "fk_Invoice_CustomerID" FOREIGN KEY ("CustomerID") REFERENCES "Customer" ("ID") ON DELETE CASCADE);`,
+		`CREATE INDEX "idx_Invoice_CustomerID" ON "Invoice" ("CustomerID");`,
+		`CREATE TABLE "Book" ("ID" bigint PRIMARY KEY,"AuthorID" bigint );`,
+		`CREATE TABLE "Shelf" ("ID" bigint PRIMARY KEY,"BookID" bigint ,CONSTRAINT "fk_Shelf_BookID" FOREIGN KEY ("BookID") REFERENCES "Book" ("ID"));`,
+		`CREATE TABLE "Author" ("ID" bigint PRIMARY KEY,"Favorite" bigint ,CONSTRAINT "fk_Author_Favorite" FOREIGN KEY ("Favorite") REFERENCES "Book" ("ID"));`,
+		`ALTER TABLE "Book" ADD CONSTRAINT "fk_Book_AuthorID" FOREIGN KEY ("AuthorID") REFERENCES "Author" ("ID");`,
+	}
+	if !reflect.DeepEqual(stmts, want) {
+		t.Fatalf("unexpected DDL:\n%s", strings.Join(stmts, "\n"))
+	}
+
+	//在空的SQLite数据库上执行生成的语句之后，迁移没有修改
+	engine := OpenDB(t)
+	stmts, err = engine.DDL(&Invoice{}, &Customer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range stmts {
+		if _, err := engine.NewSession().Raw(stmt).Exec(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if plan, _ := engine.MigratePlan(&Invoice{}, &Customer{}); len(plan.Statements) != 0 {
+		t.Fatalf("DDL should create the same tables as Migrate:\n%s", plan)
+	}
+}
+
+func TestEngine_DumpSchema(t *testing.T) {
+	engine := OpenDB(t)
+	if err := engine.Migrate(&Invoice{}, &Customer{}); err != nil {
+		t.Fatal(err)
+	}
+	stmts, err := engine.DumpSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`CREATE TABLE "Customer" ("ID" bigint PRIMARY KEY,"Email" text NOT NULL);`,
+		`CREATE UNIQUE INDEX "idx_Customer_Email" ON "Customer" ("Email");`,
+		`CREATE TABLE "Invoice" ("ID" bigint PRIMARY KEY,"CustomerID" bigint ,"Total" real ,CONSTRAINT "fk_Invoice_CustomerID" FOREIGN KEY ("CustomerID") REFERENCES "Customer" ("ID") ON DELETE CASCADE);`,
+		`CREATE INDEX "idx_Invoice_CustomerID" ON "Invoice" ("CustomerID");`,
+	}
+	if !reflect.DeepEqual(stmts, want) {
+		t.Fatalf("unexpected schema:\n%s", strings.Join(stmts, "\n"))
+	}
+}
+
+func TestEngine_DumpSchemaPostgres(t *testing.T) {
+	dial, _ := dialect.GetDialect("postgres")
+	db, rec := fakedb.Open()
+	defer db.Close()
+	rec.Rows = func(query string, args []interface{}) ([]string, [][]interface{}) {
+		table := ""
+		if len(args) > 0 {
+			table = args[0].(string)
+		}
+		switch {
+		case strings.Contains(query, "pg_tables"):
+			return []string{"tablename"}, [][]interface{}{{"Invoice"}, {"Shop"}}
+		case strings.Contains(query, "pg_attribute a JOIN") && table == "Invoice":
+			return []string{"name", "type", "notnull", "default", "pk"}, [][]interface{}{
+				{"ID", "bigint", true, "nextval('\"Invoice_ID_seq\"'::regclass)", true},
+				{"Number", "integer", true, "nextval('invoice_number'::regclass)", false},
+				{"ShopID", "bigint", false, nil, false},
+			}
+		case strings.Contains(query, "pg_attribute a JOIN"):
+			return []string{"name", "type", "notnull", "default", "pk"}, [][]interface{}{
+				{"ID", "integer", true, "nextval('\"Shop_ID_seq\"'::regclass)", true},
+			}
+		case strings.Contains(query, "contype = 'f'"):
+			if table != "Invoice" {
+				return []string{"def"}, nil
+			}
+			return []string{"def"}, [][]interface{}{
+				{`ALTER TABLE "Invoice" ADD CONSTRAINT "fk_Invoice_ShopID" FOREIGN KEY ("ShopID") REFERENCES "Shop"("ID") ON DELETE CASCADE;`},
+			}
+		case strings.Contains(query, "pg_get_constraintdef"):
+			return []string{"def"}, [][]interface{}{{`CONSTRAINT "` + table + `_pkey" PRIMARY KEY ("ID")`}}
+		case strings.Contains(query, "pg_get_indexdef") && table == "Invoice":
+			return []string{"def"}, [][]interface{}{{`CREATE INDEX "idx_Invoice_ShopID" ON public."Invoice" USING btree ("ShopID");`}}
+		}
+		return nil, nil
+	}
+	engine := &Engine{db: db, dialectSQL: dial}
+	stmts, err := engine.DumpSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	//随列建立的序列写成bigserial/serial，外键在全部表之后添加
+	want := []string{
+		`CREATE SEQUENCE IF NOT EXISTS invoice_number;`,
+		`CREATE TABLE "Invoice" (
+  "ID" bigserial NOT NULL,
+  "Number" integer NOT NULL DEFAULT nextval('invoice_number'::regclass),
+  "ShopID" bigint,
+  CONSTRAINT "Invoice_pkey" PRIMARY KEY ("ID")
+);`,
+		`CREATE INDEX "idx_Invoice_ShopID" ON public."Invoice" USING btree ("ShopID");`,
+		`CREATE TABLE "Shop" (
+  "ID" serial NOT NULL,
+  CONSTRAINT "Shop_pkey" PRIMARY KEY ("ID")
+);`,
+		`ALTER TABLE "Invoice" ADD CONSTRAINT "fk_Invoice_ShopID" FOREIGN KEY ("ShopID") REFERENCES "Shop"("ID") ON DELETE CASCADE;`,
+	}
+	if !reflect.DeepEqual(stmts, want) {
+		t.Fatalf("unexpected schema:\n%s", strings.Join(stmts, "\n"))
+	}
+}
+
+func TestEngine_DumpSchemaMySQL(t *testing.T) {
+	dial, _ := dialect.GetDialect("mysql")
+	db, rec := fakedb.Open()
+	defer db.Close()
+	rec.Rows = func(query string, args []interface{}) ([]string, [][]interface{}) {
+		switch {
+		case strings.Contains(query, "information_schema.tables"):
+			return []string{"table_name"}, [][]interface{}{{"Invoice"}}
+		case strings.HasPrefix(query, "SHOW CREATE TABLE"):
+			return []string{"Table", "Create Table"}, [][]interface{}{{"Invoice", "CREATE TABLE `Invoice` (\n" +
+				"  `ID` bigint NOT NULL AUTO_INCREMENT,\n" +
+				"  `ShopID` bigint DEFAULT NULL,\n" +
+				"  PRIMARY KEY (`ID`),\n" +
+				"  KEY `fk_Invoice_ShopID` (`ShopID`),\n" +
+				"  CONSTRAINT `fk_Invoice_ShopID` FOREIGN KEY (`ShopID`) REFERENCES `Shop` (`ID`) ON DELETE CASCADE\n" +
+				") ENGINE=InnoDB AUTO_INCREMENT=3 DEFAULT CHARSET=utf8mb4"}}
+		}
+		return nil, nil
+	}
+	engine := &Engine{db: db, dialectSQL: dial}
+	stmts, err := engine.DumpSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"CREATE TABLE `Invoice` (\n" +
+			"  `ID` bigint NOT NULL AUTO_INCREMENT,\n" +
+			"  `ShopID` bigint DEFAULT NULL,\n" +
+			"  PRIMARY KEY (`ID`),\n" +
+			"  KEY `fk_Invoice_ShopID` (`ShopID`)\n" +
+			") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;",
+		"ALTER TABLE `Invoice` ADD CONSTRAINT `fk_Invoice_ShopID` FOREIGN KEY (`ShopID`) REFERENCES `Shop` (`ID`) ON DELETE CASCADE;",
+	}
+	if !reflect.DeepEqual(stmts, want) {
+		t.Fatalf("unexpected schema:\n%s", strings.Join(stmts, "\n"))
+	}
+}
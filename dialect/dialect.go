@@ -1,5 +1,12 @@
 package dialect
-import "reflect"
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+)
 
 var dialectsMap = map[string]Dialect{}
 
@@ -8,6 +15,133 @@ var dialectsMap = map[string]Dialect{}
 type Dialect interface {
 	DataTypeOf(typ reflect.Value) string //golang类型→SQL类型
 	TableExistSQL(tableName string) (string, []interface{})
+	//自增列：传入列的类型，返回替换后的类型和追加在约束后的关键字，
+	//如SQLite返回(integer, AUTOINCREMENT)，PostgreSQL返回(bigserial, "")
+	AutoIncrement(dataType string) (string, string)
+	//给表名、列名等标识符加上引号，避免与关键字（如Order、Group）冲突，
+	//也防止来自用户输入的标识符被拼接成恶意的SQL语句
+	Quote(identifier string) string
+	Capabilities() Capabilities //数据库支持的特性
+	Inspector                   //读取数据库中已有的表结构
+}
+
+//数据库不支持某个特性时返回的错误，用errors.Is判断
+var ErrNotSupported = errors.New("not supported by the dialect")
+
+// Capabilities 描述数据库支持的特性，不同的数据库（以及同一数据库的不同版本）支持的特性不同，
+//会话在生成SQL语句前检查这些特性，不支持时返回ErrNotSupported而不是执行一条错误的语句。
+type Capabilities struct {
+	Version         string //数据库版本，未知时为空
+	Returning       bool   //INSERT/UPDATE/DELETE ... RETURNING
+	OnConflict      bool   //INSERT ... ON CONFLICT（或MySQL的ON DUPLICATE KEY UPDATE）
+	Savepoints      bool   //SAVEPOINT/RELEASE/ROLLBACK TO
+	DropColumn      bool   //ALTER TABLE ... DROP COLUMN
+	RenameColumn    bool   //ALTER TABLE ... RENAME COLUMN
+	WindowFunctions bool   //窗口函数，如ROW_NUMBER() OVER (...)
+	PartialIndex    bool   //带WHERE条件的部分索引
+}
+
+//方言可选实现的接口：连接数据库后，根据数据库的版本确定它支持的特性，
+//返回一个带有这些特性的方言。引擎在NewEngine()中调用它。
+type DetectingDialect interface {
+	Detect(db *sql.DB) (Dialect, error)
+}
+
+//方言可选实现的接口：打开数据库之前改写数据源（DSN），
+//如SQLite的外键约束默认是关闭的，且只能按连接开启，须通过DSN让连接池中的每个连接都开启它。
+type DataSourceDialect interface {
+	DataSource(source string) string
+}
+
+//比较"3.31.1"这样的版本号，a不低于b时返回true
+func versionAtLeast(a, b string) bool {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		y, _ = strconv.Atoi(bs[i])
+		if x != y {
+			return x > y
+		}
+	}
+	return true
+}
+
+//方言可选实现的接口：设置事务的加锁方式。
+//mode是数据库自己的事务模式（如SQLite的IMMEDIATE、EXCLUSIVE），
+//返回事务开始后需要立即执行的语句，mode为空时返回nil。
+type TxModeDialect interface {
+	TxModeSQL(mode string) ([]string, error)
+}
+
+//方言可选实现的接口：判断一个错误是否是暂时性的（如SQLite的SQLITE_BUSY、
+//PostgreSQL的40001 serialization_failure），这类错误的事务重新执行一遍通常就能成功。
+type RetryableDialect interface {
+	IsRetryable(err error) bool
+}
+
+//方言可选实现的接口：改写SQL语句中的占位符。
+//框架生成的SQL语句都使用"?"作为占位符，执行前交给方言改写成数据库自己的格式（如PostgreSQL的$1, $2）。
+type BindVarDialect interface {
+	Rebind(query string) string
+}
+
+// ColumnDef 迁移时一列应有的定义
+type ColumnDef struct {
+	Name          string
+	Type          string
+	NotNull       bool
+	Default       *string //默认值的SQL表达式，没有默认值时为nil
+	AutoIncrement bool
+}
+
+// ColumnChange 一列需要修改的部分
+type ColumnChange struct {
+	Type    bool
+	NotNull bool
+	Default bool
+}
+
+//方言可选实现的接口：用ALTER TABLE直接修改列的类型、NOT NULL和默认值，以及删除列上的UNIQUE约束和外键。
+//没有实现这个接口的方言通过重建表修改列（见TableRebuilder）。
+type ColumnAlterer interface {
+	AlterColumnSQL(table string, def ColumnDef, change ColumnChange) []string
+	DropUniqueSQL(table string, index string) string //index是UNIQUE约束对应的索引
+	DropForeignKeySQL(table string, name string) string
+}
+
+//方言可选实现的接口：删除索引的语句与默认的 DROP INDEX name 不同（如MySQL须给出表名）
+type IndexDropper interface {
+	DropIndexSQL(table string, index string) string
+}
+
+//方言可选实现的接口：读出数据库中建立表的语句，用于导出表结构。
+//返回表和依附于它的索引、触发器的建立语句，每条以分号结尾；顺序固定，同样的表结构总是得到同样的语句
+type SchemaDumper interface {
+	DumpTable(ctx context.Context, db Queryer, table string) ([]string, error)
+}
+
+// SchemaObject 数据库中依附于表的对象：索引、触发器、视图
+type SchemaObject struct {
+	Type string //index、trigger或view
+	Name string
+	SQL  string //建立它的语句
+}
+
+//方言可选实现的接口：数据库不能直接修改、删除列（SQLite），需要通过重建表来修改表结构：
+//新建一个表、复制数据、删除旧表、把新表改名，再重新建立索引、触发器和视图。
+//参见 https://www.sqlite.org/lang_altertable.html#otheralter
+type TableRebuilder interface {
+	//依附于表的索引和触发器，以及引用了这个表的视图
+	TableObjects(ctx context.Context, db Queryer, table string) ([]SchemaObject, error)
+	//当前连接是否开启了外键约束。开启时须在事务之外先关闭（SetForeignKeysSQL(false)），
+	//否则删除旧表时会触发其他表上的ON DELETE动作
+	ForeignKeysEnabled(ctx context.Context, db Queryer) (bool, error)
+	SetForeignKeysSQL(on bool) string
+	//检查表中违反外键约束的记录，返回每条记录的描述，没有时返回nil
+	ForeignKeyCheck(ctx context.Context, db Queryer, table string) ([]string, error)
 }
 
 //注册一个方言
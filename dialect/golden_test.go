@@ -0,0 +1,29 @@
+package dialect_test
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+//go test ./dialect -update 重新生成testdata下的golden文件
+var update = flag.Bool("update", false, "update golden files")
+
+//把生成的SQL语句与testdata/name.golden比较
+func checkGolden(t *testing.T, name string, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := ioutil.WriteFile(path, []byte(got+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got+"\n" != string(want) {
+		t.Fatalf("SQL mismatch for %s\ngot:\n%s\nwant:\n%s", name, got, want)
+	}
+}
@@ -0,0 +1,138 @@
+package dialect
+
+import (
+	"context"
+	"database/sql"
+)
+
+//读取数据库中已有的表结构（表、列、索引、外键），用于迁移时比较差异、生成文档等。
+
+// Queryer 执行查询的对象，*sql.DB、*sql.Tx 都实现了它
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Inspector 由方言实现的表结构查询方法
+type Inspector interface {
+	Tables(ctx context.Context, db Queryer) ([]string, error)                            //全部表名，按名字排序
+	Columns(ctx context.Context, db Queryer, table string) ([]ColumnInfo, error)         //按列的顺序
+	Indexes(ctx context.Context, db Queryer, table string) ([]IndexInfo, error)          //按索引名排序
+	ForeignKeys(ctx context.Context, db Queryer, table string) ([]ForeignKeyInfo, error) //按外键名排序
+}
+
+// TableInfo 一个表的结构
+type TableInfo struct {
+	Name        string
+	Columns     []ColumnInfo
+	Indexes     []IndexInfo
+	ForeignKeys []ForeignKeyInfo
+}
+
+// ColumnInfo 一列的结构
+type ColumnInfo struct {
+	Name       string
+	Type       string //数据库中声明的类型，如integer、varchar(255)
+	NotNull    bool
+	Default    *string //默认值的SQL表达式，没有默认值时为nil
+	PrimaryKey bool
+}
+
+// IndexInfo 一个索引的结构
+type IndexInfo struct {
+	Name    string
+	Unique  bool
+	Primary bool //主键自动建立的索引
+	Partial bool //带WHERE条件的部分索引
+	//由列上的UNIQUE约束自动建立（而不是用CREATE INDEX建立）
+	Constraint bool
+	Columns    []string //按索引中的顺序
+}
+
+// ForeignKeyInfo 一个外键的结构
+type ForeignKeyInfo struct {
+	Name       string //约束名，SQLite没有约束名时为空
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+	OnUpdate   string //NO ACTION、RESTRICT、CASCADE、SET NULL、SET DEFAULT
+	OnDelete   string
+}
+
+//查询只有一列字符串的结果
+func queryStrings(ctx context.Context, db Queryer, query string, args ...interface{}) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		result = append(result, s)
+	}
+	return result, rows.Err()
+}
+
+//每行依次是：列名、类型、是否NOT NULL、默认值、是否主键
+func scanColumns(rows *sql.Rows) ([]ColumnInfo, error) {
+	defer rows.Close()
+	var result []ColumnInfo
+	for rows.Next() {
+		var col ColumnInfo
+		var dflt sql.NullString
+		if err := rows.Scan(&col.Name, &col.Type, &col.NotNull, &dflt, &col.PrimaryKey); err != nil {
+			return nil, err
+		}
+		if dflt.Valid {
+			col.Default = &dflt.String
+		}
+		result = append(result, col)
+	}
+	return result, rows.Err()
+}
+
+//把每行一个列的索引查询结果合并成索引，rows须按索引名、列的顺序排序，
+//每行依次是：索引名、是否唯一、是否主键、是否部分索引、是否UNIQUE约束、列名
+func scanIndexes(rows *sql.Rows) ([]IndexInfo, error) {
+	defer rows.Close()
+	var result []IndexInfo
+	for rows.Next() {
+		var idx IndexInfo
+		var column string
+		if err := rows.Scan(&idx.Name, &idx.Unique, &idx.Primary, &idx.Partial, &idx.Constraint, &column); err != nil {
+			return nil, err
+		}
+		if n := len(result); n > 0 && result[n-1].Name == idx.Name {
+			result[n-1].Columns = append(result[n-1].Columns, column)
+			continue
+		}
+		idx.Columns = []string{column}
+		result = append(result, idx)
+	}
+	return result, rows.Err()
+}
+
+//把每行一对列的外键查询结果合并成外键，rows须按外键名、列的顺序排序，
+//每行依次是：外键名、列名、引用的表、引用的列、ON UPDATE、ON DELETE
+func scanForeignKeys(rows *sql.Rows) ([]ForeignKeyInfo, error) {
+	defer rows.Close()
+	var result []ForeignKeyInfo
+	for rows.Next() {
+		var fk ForeignKeyInfo
+		var column, refColumn string
+		if err := rows.Scan(&fk.Name, &column, &fk.RefTable, &refColumn, &fk.OnUpdate, &fk.OnDelete); err != nil {
+			return nil, err
+		}
+		if n := len(result); n > 0 && result[n-1].Name == fk.Name {
+			result[n-1].Columns = append(result[n-1].Columns, column)
+			result[n-1].RefColumns = append(result[n-1].RefColumns, refColumn)
+			continue
+		}
+		fk.Columns, fk.RefColumns = []string{column}, []string{refColumn}
+		result = append(result, fk)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,212 @@
+package dialect
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type mysql struct {
+	version string //SELECT VERSION()，为空时按MySQL 8.0
+}
+
+var _ Dialect = (*mysql)(nil)
+var _ ColumnAlterer = (*mysql)(nil)
+var _ IndexDropper = (*mysql)(nil)
+var _ DetectingDialect = (*mysql)(nil)
+
+func init() {
+	RegisterDialect("mysql", &mysql{})
+}
+
+//字符串默认映射为varchar(255)（text不能直接作为主键或建索引），更长的字段可以用type:text指定
+func (m *mysql) DataTypeOf(typ reflect.Value) string {
+	switch typ.Kind() {
+	case reflect.Bool:
+		return "tinyint(1)"
+	case reflect.Int8:
+		return "tinyint"
+	case reflect.Int16:
+		return "smallint"
+	case reflect.Int, reflect.Int32:
+		return "int"
+	case reflect.Int64:
+		return "bigint"
+	case reflect.Uint8:
+		return "tinyint unsigned"
+	case reflect.Uint16:
+		return "smallint unsigned"
+	case reflect.Uint, reflect.Uint32:
+		return "int unsigned"
+	case reflect.Uint64, reflect.Uintptr:
+		return "bigint unsigned"
+	case reflect.Float32:
+		return "float"
+	case reflect.Float64:
+		return "double"
+	case reflect.String:
+		return "varchar(255)"
+	case reflect.Array, reflect.Slice:
+		return "longblob"
+	case reflect.Struct:
+		if _, ok := typ.Interface().(time.Time); ok {
+			return "datetime(6)"
+		}
+	}
+	panic(fmt.Sprintf("invalid sql type %s (%s)", typ.Type().Name(), typ.Kind()))
+}
+
+//只在当前连接的数据库（DATABASE()）中查找
+func (m *mysql) TableExistSQL(tableName string) (string, []interface{}) {
+	args := []interface{}{tableName}
+	return "SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?", args
+}
+
+func (m *mysql) AutoIncrement(dataType string) (string, string) {
+	return dataType, "AUTO_INCREMENT"
+}
+
+//MySQL用反引号包住标识符，标识符中的反引号写两遍
+func (m *mysql) Quote(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func (m *mysql) Detect(db *sql.DB) (Dialect, error) {
+	var version string
+	if err := db.QueryRow("SELECT VERSION()").Scan(&version); err != nil {
+		return nil, err
+	}
+	return &mysql{version: version}, nil
+}
+
+//版本如8.0.36、5.7.44-log、10.6.12-MariaDB，为空时按MySQL 8.0。
+//RENAME COLUMN从MySQL 8.0、MariaDB 10.5.2开始支持，窗口函数从MySQL 8.0、MariaDB 10.2开始支持，
+//RETURNING只有MariaDB 10.5及以上支持；ON DUPLICATE KEY UPDATE各版本都支持
+func (m *mysql) Capabilities() Capabilities {
+	caps := Capabilities{
+		Version:         m.version,
+		OnConflict:      true,
+		Savepoints:      true,
+		DropColumn:      true,
+		RenameColumn:    true,
+		WindowFunctions: true,
+	}
+	if strings.Contains(m.version, "MariaDB") {
+		caps.Returning = versionAtLeast(m.version, "10.5.0")
+		caps.RenameColumn = versionAtLeast(m.version, "10.5.2")
+		caps.WindowFunctions = versionAtLeast(m.version, "10.2.0")
+	} else if m.version != "" {
+		caps.RenameColumn = versionAtLeast(m.version, "8.0.0")
+		caps.WindowFunctions = caps.RenameColumn
+	}
+	return caps
+}
+
+func (m *mysql) Tables(ctx context.Context, db Queryer) ([]string, error) {
+	return queryStrings(ctx, db, "SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() AND table_type = 'BASE TABLE' ORDER BY table_name")
+}
+
+func (m *mysql) Columns(ctx context.Context, db Queryer, table string) ([]ColumnInfo, error) {
+	rows, err := db.QueryContext(ctx, "SELECT column_name, column_type, is_nullable = 'NO', column_default, column_key = 'PRI' "+
+		"FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? ORDER BY ordinal_position", table)
+	if err != nil {
+		return nil, err
+	}
+	return scanColumns(rows)
+}
+
+//MySQL没有部分索引，主键索引的名字总是PRIMARY。
+//MySQL中UNIQUE约束就是唯一索引，information_schema.table_constraints中类型为UNIQUE的都标记为约束
+func (m *mysql) Indexes(ctx context.Context, db Queryer, table string) ([]IndexInfo, error) {
+	rows, err := db.QueryContext(ctx, "SELECT s.index_name, s.non_unique = 0, s.index_name = 'PRIMARY', 0, COALESCE(c.constraint_type = 'UNIQUE', 0), s.column_name "+
+		"FROM information_schema.statistics s LEFT JOIN information_schema.table_constraints c "+
+		"ON c.table_schema = s.table_schema AND c.table_name = s.table_name AND c.constraint_name = s.index_name "+
+		"WHERE s.table_schema = DATABASE() AND s.table_name = ? ORDER BY s.index_name, s.seq_in_index", table)
+	if err != nil {
+		return nil, err
+	}
+	return scanIndexes(rows)
+}
+
+func (m *mysql) ForeignKeys(ctx context.Context, db Queryer, table string) ([]ForeignKeyInfo, error) {
+	rows, err := db.QueryContext(ctx, "SELECT k.constraint_name, k.column_name, k.referenced_table_name, k.referenced_column_name, r.update_rule, r.delete_rule "+
+		"FROM information_schema.key_column_usage k JOIN information_schema.referential_constraints r "+
+		"ON r.constraint_schema = k.constraint_schema AND r.constraint_name = k.constraint_name "+
+		"WHERE k.table_schema = DATABASE() AND k.table_name = ? ORDER BY k.constraint_name, k.ordinal_position", table)
+	if err != nil {
+		return nil, err
+	}
+	return scanForeignKeys(rows)
+}
+
+//表选项中的AUTO_INCREMENT=下一个自增值，随数据变化，导出时去掉
+var autoIncrementRegexp = regexp.MustCompile(` AUTO_INCREMENT=\d+`)
+
+//SHOW CREATE TABLE的结果包括索引和外键，每行依次是：表名、建表语句。
+//外键从建表语句中拿出来，写成建表之后的ALTER TABLE，被引用的表不必先建立
+func (m *mysql) DumpTable(ctx context.Context, db Queryer, table string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SHOW CREATE TABLE "+m.Quote(table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var stmts, foreignKeys []string
+	for rows.Next() {
+		var name, stmt string
+		if err := rows.Scan(&name, &stmt); err != nil {
+			return nil, err
+		}
+		stmt, fks := m.splitForeignKeys(table, autoIncrementRegexp.ReplaceAllString(stmt, ""))
+		stmts = append(stmts, stmt+";")
+		foreignKeys = append(foreignKeys, fks...)
+	}
+	return append(stmts, foreignKeys...), rows.Err()
+}
+
+//SHOW CREATE TABLE的每个列、索引和约束各占一行，以", "结尾，最后一行以")"开头
+func (m *mysql) splitForeignKeys(table string, stmt string) (string, []string) {
+	var lines, fks []string
+	for _, line := range strings.Split(stmt, "\n") {
+		def := strings.TrimSuffix(strings.TrimSpace(line), ",")
+		if strings.HasPrefix(def, "CONSTRAINT ") && strings.Contains(def, " FOREIGN KEY ") {
+			fks = append(fks, fmt.Sprintf("ALTER TABLE %s ADD %s;", m.Quote(table), def))
+			continue
+		}
+		if strings.HasPrefix(line, ")") && len(lines) > 0 {
+			lines[len(lines)-1] = strings.TrimSuffix(lines[len(lines)-1], ",")
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), fks
+}
+
+//MODIFY COLUMN需要给出列的完整定义（不含UNIQUE，否则每次都会多建一个唯一索引）
+func (m *mysql) AlterColumnSQL(table string, def ColumnDef, change ColumnChange) []string {
+	col := m.Quote(def.Name) + " " + def.Type
+	if def.NotNull {
+		col += " NOT NULL"
+	}
+	if def.Default != nil {
+		col += " DEFAULT " + *def.Default
+	}
+	if def.AutoIncrement {
+		col += " AUTO_INCREMENT"
+	}
+	return []string{fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s;", m.Quote(table), col)}
+}
+
+func (m *mysql) DropUniqueSQL(table string, index string) string {
+	return m.DropIndexSQL(table, index)
+}
+
+func (m *mysql) DropForeignKeySQL(table string, name string) string {
+	return fmt.Sprintf("ALTER TABLE %s DROP FOREIGN KEY %s;", m.Quote(table), m.Quote(name))
+}
+
+func (m *mysql) DropIndexSQL(table string, index string) string {
+	return fmt.Sprintf("ALTER TABLE %s DROP INDEX %s;", m.Quote(table), m.Quote(index))
+}
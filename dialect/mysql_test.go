@@ -0,0 +1,145 @@
+package dialect_test
+
+import (
+	"errors"
+	"myorm/dialect"
+	"myorm/internal/fakedb"
+	"myorm/schema"
+	"myorm/session"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Order struct {
+	ID       int64 `myorm:"PRIMARY KEY;autoIncrement"`
+	Group    string `myorm:"index"`
+	Paid     bool   `myorm:"index:idx_paid_created,priority:1"`
+	Quantity uint16
+	Price    float64
+	Note     string `myorm:"type:text"`
+	Payload  []byte
+	Created  time.Time `myorm:"index:idx_paid_created,priority:2"`
+}
+
+func TestMySQL_DataTypeOf(t *testing.T) {
+	dial, ok := dialect.GetDialect("mysql")
+	if !ok {
+		t.Fatal("mysql dialect not registered")
+	}
+	cases := []struct {
+		value interface{}
+		want  string
+	}{
+		{true, "tinyint(1)"},
+		{int8(0), "tinyint"},
+		{0, "int"},
+		{int64(0), "bigint"},
+		{uint(0), "int unsigned"},
+		{uint64(0), "bigint unsigned"},
+		{float32(0), "float"},
+		{0.0, "double"},
+		{"", "varchar(255)"},
+		{[]byte{}, "longblob"},
+		{time.Time{}, "datetime(6)"},
+	}
+	for _, c := range cases {
+		if got := dial.DataTypeOf(reflect.ValueOf(c.value)); got != c.want {
+			t.Errorf("DataTypeOf(%T) = %s, want %s", c.value, got, c.want)
+		}
+	}
+}
+
+func TestMySQL_SQL(t *testing.T) {
+	dial, _ := dialect.GetDialect("mysql")
+	db, rec := fakedb.Open()
+	defer db.Close()
+	s := session.New(db, dial).Model(&Order{})
+	_ = s.DropTable()
+	_ = s.CreateTable()
+	_ = s.HasTable()
+	_, _ = s.Insert(&Order{Group: "a"})
+	_, _ = s.Where("Price > ?", 10).Update("Paid", true)
+	var orders []Order
+	_ = s.Where("Paid = ?", true).OrderByColumn("Price", true).Limit(3).Find(&orders)
+	checkGolden(t, "mysql", rec.Queries())
+}
+
+func TestMySQL_Index(t *testing.T) {
+	dial, _ := dialect.GetDialect("mysql")
+	s := session.New(nil, dial).Model(&Order{})
+	if _, err := s.CreateIndexSQL(&schema.Index{Name: "idx_paid", Columns: []string{"Paid"}, Where: "Paid = 1"}); !errors.Is(err, dialect.ErrNotSupported) {
+		t.Fatal("MySQL doesn't support partial indexes", err)
+	}
+	if got := s.DropIndexSQL("idx_paid"); got != "ALTER TABLE `Order` DROP INDEX `idx_paid`;" {
+		t.Fatal("unexpected drop index statement", got)
+	}
+}
+
+func TestMySQL_Quote(t *testing.T) {
+	dial, _ := dialect.GetDialect("mysql")
+	if got := dial.Quote("we`ird"); got != "`we``ird`" {
+		t.Fatal("failed to quote identifier", got)
+	}
+}
+
+func TestMySQL_Inspect(t *testing.T) {
+	dial, _ := dialect.GetDialect("mysql")
+	db, rec := fakedb.Open()
+	defer db.Close()
+	rec.Rows = func(query string, args []interface{}) ([]string, [][]interface{}) {
+		switch {
+		case strings.Contains(query, "information_schema.columns"):
+			return []string{"name", "type", "notnull", "default", "pk"}, [][]interface{}{
+				{"ID", "bigint", int64(1), nil, int64(1)},
+				{"Group", "varchar(64)", int64(0), "a", int64(0)},
+			}
+		case strings.Contains(query, "information_schema.statistics"):
+			return []string{"name", "unique", "primary", "partial", "constraint", "column"}, [][]interface{}{
+				{"PRIMARY", int64(1), int64(1), int64(0), int64(0), "ID"},
+				{"idx_group_price", int64(0), int64(0), int64(0), int64(0), "Group"},
+				{"idx_group_price", int64(0), int64(0), int64(0), int64(0), "Price"},
+			}
+		}
+		return nil, nil
+	}
+	s := session.New(db, dial)
+	info, err := s.Inspect("Order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := info.Columns[1]; c.Type != "varchar(64)" || c.NotNull || *c.Default != "a" || !info.Columns[0].PrimaryKey {
+		t.Fatal("failed to parse columns", info.Columns)
+	}
+	if len(info.Indexes) != 2 || !info.Indexes[0].Primary || !reflect.DeepEqual(info.Indexes[1].Columns, []string{"Group", "Price"}) {
+		t.Fatal("failed to parse indexes", info.Indexes)
+	}
+	if args := rec.Statements()[0].Args; !reflect.DeepEqual(args, []interface{}{"Order"}) {
+		t.Fatal("table name should be passed as a parameter", args)
+	}
+}
+
+func TestMySQL_Detect(t *testing.T) {
+	dial, _ := dialect.GetDialect("mysql")
+	for version, want := range map[string][3]bool{ //RenameColumn, WindowFunctions, Returning
+		"8.0.36":          {true, true, false},
+		"5.7.44-log":      {false, false, false},
+		"10.4.32-MariaDB": {false, true, false},
+		"10.6.12-MariaDB": {true, true, true},
+	} {
+		db, rec := fakedb.Open()
+		rec.Rows = func(query string, args []interface{}) ([]string, [][]interface{}) {
+			return []string{"VERSION()"}, [][]interface{}{{version}}
+		}
+		detected, err := dial.(dialect.DetectingDialect).Detect(db)
+		_ = db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		caps := detected.Capabilities()
+		if caps.Version != version || [3]bool{caps.RenameColumn, caps.WindowFunctions, caps.Returning} != want || !caps.OnConflict {
+			t.Errorf("unexpected capabilities of %s: %+v", version, caps)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package dialect
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type postgres struct{}
+
+var _ Dialect = (*postgres)(nil)
+var _ BindVarDialect = (*postgres)(nil)
+var _ RetryableDialect = (*postgres)(nil)
+var _ ColumnAlterer = (*postgres)(nil)
+
+func init() {
+	RegisterDialect("postgres", &postgres{})
+}
+
+func (p *postgres) DataTypeOf(typ reflect.Value) string {
+	switch typ.Interface().(type) {
+	case time.Time:
+		return "timestamptz"
+	case json.RawMessage:
+		return "jsonb"
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int8, reflect.Int16, reflect.Uint8:
+		return "smallint"
+	case reflect.Int, reflect.Int32, reflect.Uint16:
+		return "integer"
+	case reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return "bigint"
+	case reflect.Float32:
+		return "real"
+	case reflect.Float64:
+		return "double precision"
+	case reflect.String:
+		return "text"
+	case reflect.Array, reflect.Slice:
+		return "bytea"
+	case reflect.Map:
+		return "jsonb"
+	}
+	panic(fmt.Sprintf("invalid sql type %s (%s)", typ.Type().Name(), typ.Kind()))
+}
+
+//只在当前模式（current_schema()，通常是public）中查找
+func (p *postgres) TableExistSQL(tableName string) (string, []interface{}) {
+	args := []interface{}{tableName}
+	return "SELECT tablename FROM pg_catalog.pg_tables WHERE schemaname = current_schema() AND tablename = ?", args
+}
+
+//PostgreSQL用serial类型实现自增列
+func (p *postgres) AutoIncrement(dataType string) (string, string) {
+	switch dataType {
+	case "smallint":
+		return "smallserial", ""
+	case "integer":
+		return "serial", ""
+	}
+	return "bigserial", ""
+}
+
+//PostgreSQL用双引号包住标识符，标识符中的双引号写两遍
+func (p *postgres) Quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+//按PostgreSQL 9.5及以上（ON CONFLICT从9.5开始支持）
+func (p *postgres) Capabilities() Capabilities {
+	return Capabilities{
+		Returning:       true,
+		OnConflict:      true,
+		Savepoints:      true,
+		DropColumn:      true,
+		RenameColumn:    true,
+		WindowFunctions: true,
+		PartialIndex:    true,
+	}
+}
+
+//把占位符"?"依次改写成$1, $2, ...
+//单引号字符串、双引号标识符、美元符号引用的字符串（$tag$...$tag$）和注释中的"?"保持不变。
+func (p *postgres) Rebind(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+	var sb strings.Builder
+	n := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '?':
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		case c == '\'' || c == '"':
+			end := closeQuote(query, i, c)
+			sb.WriteString(query[i:end])
+			i = end - 1
+			continue
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			sb.WriteString(query[i : i+end])
+			i += end - 1
+			continue
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query) - i
+			} else {
+				end += 4
+			}
+			sb.WriteString(query[i : i+end])
+			i += end - 1
+			continue
+		case c == '$':
+			if tag := dollarTag(query[i:]); tag != "" {
+				end := strings.Index(query[i+len(tag):], tag)
+				if end < 0 {
+					end = len(query) - i
+				} else {
+					end += 2 * len(tag)
+				}
+				sb.WriteString(query[i : i+end])
+				i += end - 1
+				continue
+			}
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
+
+//返回从start处的引号开始、到配对的引号之后的位置，连续两个引号表示转义
+func closeQuote(query string, start int, quote byte) int {
+	for i := start + 1; i < len(query); i++ {
+		if query[i] != quote {
+			continue
+		}
+		if i+1 < len(query) && query[i+1] == quote {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(query)
+}
+
+//美元符号引用的开始标记，如$$或$body$；不是时返回空字符串（$1这样的参数不是）
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if c == '$' {
+			return s[:i+1]
+		}
+		isLetter := c == '_' || (c|0x20 >= 'a' && c|0x20 <= 'z')
+		if !isLetter && !(i > 1 && c >= '0' && c <= '9') {
+			return ""
+		}
+	}
+	return ""
+}
+
+//40001 serialization_failure（可串行化隔离级别下的冲突）和 40P01 deadlock_detected。
+//lib/pq 和 pgx 的错误类型都提供了 SQLState() 方法
+func (p *postgres) IsRetryable(err error) bool {
+	var e interface{ SQLState() string }
+	if !errors.As(err, &e) {
+		return false
+	}
+	switch e.SQLState() {
+	case "40001", "40P01":
+		return true
+	}
+	return false
+}
+
+func (p *postgres) Tables(ctx context.Context, db Queryer) ([]string, error) {
+	return queryStrings(ctx, db, "SELECT tablename FROM pg_catalog.pg_tables WHERE schemaname = current_schema() ORDER BY tablename")
+}
+
+func (p *postgres) Columns(ctx context.Context, db Queryer, table string) ([]ColumnInfo, error) {
+	rows, err := db.QueryContext(ctx, p.Rebind("SELECT a.attname, format_type(a.atttypid, a.atttypmod), a.attnotnull, "+
+		"pg_get_expr(d.adbin, d.adrelid), COALESCE(i.indisprimary, false) "+
+		"FROM pg_attribute a JOIN pg_class t ON t.oid = a.attrelid JOIN pg_namespace n ON n.oid = t.relnamespace "+
+		"LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum "+
+		"LEFT JOIN pg_index i ON i.indrelid = a.attrelid AND i.indisprimary AND a.attnum = ANY(i.indkey) "+
+		"WHERE n.nspname = current_schema() AND t.relname = ? AND a.attnum > 0 AND NOT a.attisdropped ORDER BY a.attnum"), table)
+	if err != nil {
+		return nil, err
+	}
+	return scanColumns(rows)
+}
+
+func (p *postgres) Indexes(ctx context.Context, db Queryer, table string) ([]IndexInfo, error) {
+	rows, err := db.QueryContext(ctx, p.Rebind("SELECT i.relname, ix.indisunique, ix.indisprimary, ix.indpred IS NOT NULL, "+
+		"EXISTS (SELECT 1 FROM pg_constraint c WHERE c.conindid = ix.indexrelid AND c.contype = 'u'), a.attname "+
+		"FROM pg_index ix JOIN pg_class t ON t.oid = ix.indrelid JOIN pg_class i ON i.oid = ix.indexrelid "+
+		"JOIN pg_namespace n ON n.oid = t.relnamespace "+
+		"JOIN LATERAL unnest(ix.indkey) WITH ORDINALITY AS k(attnum, ord) ON true "+
+		"JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum "+
+		"WHERE n.nspname = current_schema() AND t.relname = ? ORDER BY i.relname, k.ord"), table)
+	if err != nil {
+		return nil, err
+	}
+	return scanIndexes(rows)
+}
+
+//pg_constraint中的动作用一个字母表示
+var pgFKActions = map[string]string{"a": "NO ACTION", "r": "RESTRICT", "c": "CASCADE", "n": "SET NULL", "d": "SET DEFAULT"}
+
+func (p *postgres) ForeignKeys(ctx context.Context, db Queryer, table string) ([]ForeignKeyInfo, error) {
+	rows, err := db.QueryContext(ctx, p.Rebind("SELECT c.conname, a.attname, rt.relname, ra.attname, c.confupdtype, c.confdeltype "+
+		"FROM pg_constraint c JOIN pg_class t ON t.oid = c.conrelid JOIN pg_namespace n ON n.oid = t.relnamespace "+
+		"JOIN pg_class rt ON rt.oid = c.confrelid "+
+		"JOIN LATERAL unnest(c.conkey, c.confkey) WITH ORDINALITY AS k(col, refcol, ord) ON true "+
+		"JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.col "+
+		"JOIN pg_attribute ra ON ra.attrelid = c.confrelid AND ra.attnum = k.refcol "+
+		"WHERE c.contype = 'f' AND n.nspname = current_schema() AND t.relname = ? ORDER BY c.conname, k.ord"), table)
+	if err != nil {
+		return nil, err
+	}
+	fks, err := scanForeignKeys(rows)
+	for i := range fks {
+		fks[i].OnUpdate, fks[i].OnDelete = pgFKActions[fks[i].OnUpdate], pgFKActions[fks[i].OnDelete]
+	}
+	return fks, err
+}
+
+//自增列的默认值，如nextval('"Invoice_ID_seq"'::regclass)
+var nextvalRegexp = regexp.MustCompile(`^nextval\('(.+)'::regclass\)$`)
+
+var serialTypes = map[string]string{"smallint": "smallserial", "integer": "serial", "bigint": "bigserial"}
+
+//PostgreSQL不保存建表语句，由系统目录还原：列来自Columns()，约束来自pg_get_constraintdef()，
+//不属于约束的索引来自pg_get_indexdef()，都按名字排序。
+//随列建立的序列（表名_列名_seq）写成serial/bigserial，其他序列在建表之前用CREATE SEQUENCE建立；
+//外键写成建表之后的ALTER TABLE，被引用的表不必先建立
+func (p *postgres) DumpTable(ctx context.Context, db Queryer, table string) ([]string, error) {
+	columns, err := p.Columns(ctx, db, table)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s doesn't exist", table)
+	}
+	var stmts, defs []string
+	for _, col := range columns {
+		typ, dflt := col.Type, col.Default
+		if dflt != nil {
+			if m := nextvalRegexp.FindStringSubmatch(*dflt); m != nil {
+				if serial, ok := serialTypes[typ]; ok && strings.Trim(m[1], `"`) == table+"_"+col.Name+"_seq" {
+					typ, dflt = serial, nil
+				} else {
+					stmts = append(stmts, "CREATE SEQUENCE IF NOT EXISTS "+m[1]+";")
+				}
+			}
+		}
+		def := p.Quote(col.Name) + " " + typ
+		if col.NotNull {
+			def += " NOT NULL"
+		}
+		if dflt != nil {
+			def += " DEFAULT " + *dflt
+		}
+		defs = append(defs, def)
+	}
+	const constraintSQL = "FROM pg_constraint c JOIN pg_class t ON t.oid = c.conrelid JOIN pg_namespace n ON n.oid = t.relnamespace " +
+		"WHERE n.nspname = current_schema() AND t.relname = ? "
+	constraints, err := queryStrings(ctx, db, p.Rebind("SELECT 'CONSTRAINT ' || quote_ident(c.conname) || ' ' || pg_get_constraintdef(c.oid) "+
+		constraintSQL+"AND c.contype NOT IN ('n', 'f') ORDER BY c.conname"), table)
+	if err != nil {
+		return nil, err
+	}
+	defs = append(defs, constraints...)
+	foreignKeys, err := queryStrings(ctx, db, p.Rebind("SELECT 'ALTER TABLE ' || quote_ident(t.relname) || ' ADD CONSTRAINT ' || "+
+		"quote_ident(c.conname) || ' ' || pg_get_constraintdef(c.oid) || ';' "+constraintSQL+"AND c.contype = 'f' ORDER BY c.conname"), table)
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := queryStrings(ctx, db, p.Rebind("SELECT pg_get_indexdef(ix.indexrelid) || ';' "+
+		"FROM pg_index ix JOIN pg_class t ON t.oid = ix.indrelid JOIN pg_class i ON i.oid = ix.indexrelid "+
+		"JOIN pg_namespace n ON n.oid = t.relnamespace "+
+		"WHERE n.nspname = current_schema() AND t.relname = ? "+
+		"AND NOT EXISTS (SELECT 1 FROM pg_constraint c WHERE c.conindid = ix.indexrelid) ORDER BY i.relname"), table)
+	if err != nil {
+		return nil, err
+	}
+	stmts = append(stmts, fmt.Sprintf("CREATE TABLE %s (\n  %s\n);", p.Quote(table), strings.Join(defs, ",\n  ")))
+	stmts = append(stmts, indexes...)
+	return append(stmts, foreignKeys...), nil
+}
+
+//类型、NOT NULL、默认值分别修改，修改类型时用USING把旧值转换为新类型
+func (p *postgres) AlterColumnSQL(table string, def ColumnDef, change ColumnChange) []string {
+	alter := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s ", p.Quote(table), p.Quote(def.Name))
+	var stmts []string
+	if change.Type {
+		stmts = append(stmts, alter+fmt.Sprintf("TYPE %s USING %s::%s;", def.Type, p.Quote(def.Name), def.Type))
+	}
+	if change.NotNull && def.NotNull {
+		stmts = append(stmts, alter+"SET NOT NULL;")
+	} else if change.NotNull {
+		stmts = append(stmts, alter+"DROP NOT NULL;")
+	}
+	if change.Default && def.Default != nil {
+		stmts = append(stmts, alter+"SET DEFAULT "+*def.Default+";")
+	} else if change.Default {
+		stmts = append(stmts, alter+"DROP DEFAULT;")
+	}
+	return stmts
+}
+
+//列上的UNIQUE约束与它的索引同名
+func (p *postgres) DropUniqueSQL(table string, index string) string {
+	return fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s;", p.Quote(table), p.Quote(index))
+}
+
+func (p *postgres) DropForeignKeySQL(table string, name string) string {
+	return fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s;", p.Quote(table), p.Quote(name))
+}
@@ -0,0 +1,71 @@
+package dialect_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"myorm/dialect"
+	"myorm/internal/fakedb"
+	"myorm/session"
+	"reflect"
+	"testing"
+)
+
+func TestPostgres_Rebind(t *testing.T) {
+	dial, _ := dialect.GetDialect("postgres")
+	r := dial.(dialect.BindVarDialect)
+	cases := []struct{ query, want string }{
+		{"SELECT * FROM User WHERE Name = ? AND Age > ?", "SELECT * FROM User WHERE Name = $1 AND Age > $2"},
+		{"SELECT '?', 'it''s ?' WHERE a = ?", "SELECT '?', 'it''s ?' WHERE a = $1"},
+		{`SELECT "col?" FROM t WHERE b = ?`, `SELECT "col?" FROM t WHERE b = $1`},
+		{"SELECT $$ ? $$, $x$?$x$ WHERE c = ? -- ?\nAND d = ? /* ? */", "SELECT $$ ? $$, $x$?$x$ WHERE c = $1 -- ?\nAND d = $2 /* ? */"},
+		{"SELECT 1", "SELECT 1"},
+	}
+	for _, c := range cases {
+		if got := r.Rebind(c.query); got != c.want {
+			t.Errorf("Rebind(%q) = %q, want %q", c.query, got, c.want)
+		}
+	}
+}
+
+type pgError struct{ code string }
+
+func (e *pgError) Error() string    { return "pq: " + e.code }
+func (e *pgError) SQLState() string { return e.code }
+
+func TestPostgres_IsRetryable(t *testing.T) {
+	dial, _ := dialect.GetDialect("postgres")
+	r := dial.(dialect.RetryableDialect)
+	if !r.IsRetryable(fmt.Errorf("commit: %w", &pgError{"40001"})) {
+		t.Fatal("serialization failure should be retryable")
+	}
+	if r.IsRetryable(&pgError{"23505"}) || r.IsRetryable(errors.New("40001")) {
+		t.Fatal("unique violation should not be retryable")
+	}
+}
+
+func TestPostgres_SQL(t *testing.T) {
+	dial, _ := dialect.GetDialect("postgres")
+	db, rec := fakedb.Open()
+	defer db.Close()
+	s := session.New(db, dial).Model(&Order{})
+	_ = s.DropTable()
+	_ = s.CreateTable()
+	_ = s.HasTable()
+	_, _ = s.Insert(&Order{Group: "a"}, &Order{Group: "b"})
+	_, _ = s.Insert(&Order{ID: 7, Group: "c"})
+	_, _ = s.Where("Price > ? AND Note <> '?'", 10).Update("Paid", true)
+	var orders []Order
+	_ = s.Where("Paid = ?", true).OrderByColumn("Price", true).Limit(3).Find(&orders)
+	checkGolden(t, "postgres", rec.Queries())
+}
+
+func TestPostgres_DataTypeOf(t *testing.T) {
+	dial, _ := dialect.GetDialect("postgres")
+	if got := dial.DataTypeOf(reflect.ValueOf(json.RawMessage{})); got != "jsonb" {
+		t.Fatal("json.RawMessage should map to jsonb, got", got)
+	}
+	if got := dial.DataTypeOf(reflect.ValueOf(map[string]interface{}{})); got != "jsonb" {
+		t.Fatal("map should map to jsonb, got", got)
+	}
+}
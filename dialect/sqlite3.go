@@ -1,14 +1,29 @@
 package dialect
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"reflect"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
+
+	sqlite3driver "github.com/mattn/go-sqlite3"
 )
 
-type sqlite3 struct{}
+type sqlite3 struct {
+	version string //sqlite_version()，为空时使用链接进程序的SQLite库的版本
+}
 
 var _ Dialect = (*sqlite3)(nil)
+var _ TxModeDialect = (*sqlite3)(nil)
+var _ RetryableDialect = (*sqlite3)(nil)
+var _ DetectingDialect = (*sqlite3)(nil)
+var _ TableRebuilder = (*sqlite3)(nil)
+var _ DataSourceDialect = (*sqlite3)(nil)
 
 func init() {
 	RegisterDialect("sqlite3", &sqlite3{})
@@ -40,4 +55,267 @@ func (s *sqlite3) DataTypeOf(typ reflect.Value) string {
 func (s *sqlite3) TableExistSQL(tableName string) (string, []interface{}) {
 	args := []interface{}{tableName}
 	return "SELECT name FROM sqlite_master WHERE type='table' and name = ?", args
+}
+
+//SQLite的自增列必须是 INTEGER PRIMARY KEY，int64等映射成的bigint也须改为integer
+func (s *sqlite3) AutoIncrement(dataType string) (string, string) {
+	return "integer", "AUTOINCREMENT"
+}
+
+//SQLite用双引号包住标识符，标识符中的双引号写两遍
+func (s *sqlite3) Quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+//go-sqlite3在打开连接时按DSN中的_foreign_keys参数执行PRAGMA foreign_keys，
+//DSN中没有指定时加上_foreign_keys=1，指定了（包括简写_fk）则尊重用户的设置
+func (s *sqlite3) DataSource(source string) string {
+	if strings.Contains(source, "_foreign_keys=") || strings.Contains(source, "_fk=") {
+		return source
+	}
+	if strings.Contains(source, "?") {
+		return source + "&_foreign_keys=1"
+	}
+	return source + "?_foreign_keys=1"
+}
+
+//go-sqlite3 的 BeginTx 忽略事务选项，总是执行 "BEGIN"（即DEFERRED）。
+//此时事务还没有拿到任何锁，回滚它不会有副作用，随后在同一个连接上重新以指定的模式开始事务，
+//database/sql 之后的 Commit/Rollback 作用在新的事务上。
+//BEGIN失败（如拿不到锁时的SQLITE_BUSY）时连接上没有事务，会话会丢弃这个*sql.Tx并返回错误，见Session.BeginTx。
+func (s *sqlite3) TxModeSQL(mode string) ([]string, error) {
+	switch strings.ToUpper(mode) {
+	case "", "DEFERRED":
+		return nil, nil
+	case "IMMEDIATE", "EXCLUSIVE":
+		return []string{"ROLLBACK", "BEGIN " + strings.ToUpper(mode)}, nil
+	}
+	return nil, fmt.Errorf("%w: sqlite3 transaction mode %s", ErrNotSupported, mode)
+}
+
+//SQLITE_BUSY：数据库文件被其他连接锁住；SQLITE_LOCKED：同一连接内的表锁冲突
+func (s *sqlite3) IsRetryable(err error) bool {
+	var e sqlite3driver.Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	return e.Code == sqlite3driver.ErrBusy || e.Code == sqlite3driver.ErrLocked
+}
+
+func (s *sqlite3) Detect(db *sql.DB) (Dialect, error) {
+	var version string
+	if err := db.QueryRow("SELECT sqlite_version()").Scan(&version); err != nil {
+		return nil, err
+	}
+	return &sqlite3{version: version}, nil
+}
+
+//各特性从哪个版本开始支持：https://www.sqlite.org/changes.html
+func (s *sqlite3) Capabilities() Capabilities {
+	version := s.version
+	if version == "" {
+		version, _, _ = sqlite3driver.Version()
+	}
+	return Capabilities{
+		Version:         version,
+		Returning:       versionAtLeast(version, "3.35.0"),
+		OnConflict:      versionAtLeast(version, "3.24.0"),
+		Savepoints:      true,
+		DropColumn:      versionAtLeast(version, "3.35.0"),
+		RenameColumn:    versionAtLeast(version, "3.25.0"),
+		WindowFunctions: versionAtLeast(version, "3.25.0"),
+		PartialIndex:    versionAtLeast(version, "3.8.0"),
+	}
+}
+
+func (s *sqlite3) Tables(ctx context.Context, db Queryer) ([]string, error) {
+	return queryStrings(ctx, db, "SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+}
+
+//PRAGMA table_info 每行依次是：cid, name, type, notnull, dflt_value, pk
+func (s *sqlite3) Columns(ctx context.Context, db Queryer, table string) ([]ColumnInfo, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s)", s.Quote(table)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []ColumnInfo
+	for rows.Next() {
+		var col ColumnInfo
+		var cid, pk int
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &col.Name, &col.Type, &col.NotNull, &dflt, &pk); err != nil {
+			return nil, err
+		}
+		if dflt.Valid {
+			col.Default = &dflt.String
+		}
+		col.PrimaryKey = pk > 0
+		result = append(result, col)
+	}
+	return result, rows.Err()
+}
+
+//PRAGMA index_list 每行依次是：seq, name, unique, origin, partial，
+//origin为pk表示主键的索引，为u表示UNIQUE约束的索引，为c表示CREATE INDEX建立的索引；
+//再用 PRAGMA index_info 查询每个索引的列。
+func (s *sqlite3) Indexes(ctx context.Context, db Queryer, table string) ([]IndexInfo, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("PRAGMA index_list(%s)", s.Quote(table)))
+	if err != nil {
+		return nil, err
+	}
+	var result []IndexInfo
+	for rows.Next() {
+		var idx IndexInfo
+		var seq int
+		var origin string
+		if err := rows.Scan(&seq, &idx.Name, &idx.Unique, &origin, &idx.Partial); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		idx.Primary, idx.Constraint = origin == "pk", origin == "u"
+		result = append(result, idx)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	//在事务中只有一个连接，须先关闭上一个查询再执行下一个
+	for i := range result {
+		if result[i].Columns, err = s.indexColumns(ctx, db, result[i].Name); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+//PRAGMA index_info 每行依次是：seqno, cid, name
+func (s *sqlite3) indexColumns(ctx context.Context, db Queryer, index string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("PRAGMA index_info(%s)", s.Quote(index)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var columns []string
+	for rows.Next() {
+		var seqno, cid int
+		var name sql.NullString
+		if err := rows.Scan(&seqno, &cid, &name); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name.String)
+	}
+	return columns, rows.Err()
+}
+
+//PRAGMA foreign_key_list 每行依次是：id, seq, table, from, to, on_update, on_delete, match，
+//同一个外键的多列id相同。SQLite不保存外键的约束名；to为NULL表示引用的是主键。
+func (s *sqlite3) ForeignKeys(ctx context.Context, db Queryer, table string) ([]ForeignKeyInfo, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("PRAGMA foreign_key_list(%s)", s.Quote(table)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []ForeignKeyInfo
+	last := -1
+	for rows.Next() {
+		var id, seq int
+		var fk ForeignKeyInfo
+		var from, match string
+		var to sql.NullString
+		if err := rows.Scan(&id, &seq, &fk.RefTable, &from, &to, &fk.OnUpdate, &fk.OnDelete, &match); err != nil {
+			return nil, err
+		}
+		if id == last {
+			n := len(result) - 1
+			result[n].Columns = append(result[n].Columns, from)
+			result[n].RefColumns = append(result[n].RefColumns, to.String)
+			continue
+		}
+		last = id
+		fk.Columns, fk.RefColumns = []string{from}, []string{to.String}
+		result = append(result, fk)
+	}
+	return result, rows.Err()
+}
+
+//sqlite_master中保存着建立时的原始语句，依次返回表、索引、触发器，同类按名字排序
+func (s *sqlite3) DumpTable(ctx context.Context, db Queryer, table string) ([]string, error) {
+	stmts, err := queryStrings(ctx, db, "SELECT sql || ';' FROM sqlite_master WHERE tbl_name = ? AND sql IS NOT NULL "+
+		"ORDER BY CASE type WHEN 'table' THEN 0 WHEN 'index' THEN 1 ELSE 2 END, name", table)
+	if err == nil && len(stmts) == 0 {
+		err = fmt.Errorf("table %s doesn't exist", table)
+	}
+	return stmts, err
+}
+
+//自动建立的索引（如UNIQUE约束的sqlite_autoindex_*）没有建立语句，随表一起重建
+//视图没有tbl_name之外的依赖记录，按建立语句中是否出现表名判断，表名前后不能是标识符中的字符，
+//如表User不匹配引用UserProfile的视图
+func (s *sqlite3) TableObjects(ctx context.Context, db Queryer, table string) ([]SchemaObject, error) {
+	rows, err := db.QueryContext(ctx, "SELECT type, name, sql FROM sqlite_master "+
+		"WHERE sql IS NOT NULL AND ((type IN ('index', 'trigger') AND tbl_name = ?) OR type = 'view') "+
+		"ORDER BY type, name", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	//SQLite的标识符不区分大小写
+	ref := regexp.MustCompile(`(?i)(^|[^\w$])` + regexp.QuoteMeta(table) + `($|[^\w$])`)
+	var result []SchemaObject
+	for rows.Next() {
+		var obj SchemaObject
+		if err := rows.Scan(&obj.Type, &obj.Name, &obj.SQL); err != nil {
+			return nil, err
+		}
+		if obj.Type == "view" && !ref.MatchString(obj.SQL) {
+			continue
+		}
+		result = append(result, obj)
+	}
+	return result, rows.Err()
+}
+
+func (s *sqlite3) ForeignKeysEnabled(ctx context.Context, db Queryer) (bool, error) {
+	rows, err := db.QueryContext(ctx, "PRAGMA foreign_keys")
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	var on bool
+	if rows.Next() {
+		if err := rows.Scan(&on); err != nil {
+			return false, err
+		}
+	}
+	return on, rows.Err()
+}
+
+//在事务中执行无效，须在事务开始前、提交后执行
+func (s *sqlite3) SetForeignKeysSQL(on bool) string {
+	if on {
+		return "PRAGMA foreign_keys = ON"
+	}
+	return "PRAGMA foreign_keys = OFF"
+}
+
+//PRAGMA foreign_key_check 每行依次是：table, rowid, parent, fkid
+func (s *sqlite3) ForeignKeyCheck(ctx context.Context, db Queryer, table string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("PRAGMA foreign_key_check(%s)", s.Quote(table)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []string
+	for rows.Next() {
+		var name, parent string
+		var rowid sql.NullInt64
+		var fkid int
+		if err := rows.Scan(&name, &rowid, &parent, &fkid); err != nil {
+			return nil, err
+		}
+		result = append(result, fmt.Sprintf("%s(rowid=%d) references missing row in %s", name, rowid.Int64, parent))
+	}
+	return result, rows.Err()
 }
\ No newline at end of file
@@ -0,0 +1,89 @@
+package dialect
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestVersionAtLeast(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want bool
+	}{
+		{"3.31.1", "3.25.0", true},
+		{"3.31.1", "3.35.0", false},
+		{"3.35", "3.35.0", true},
+		{"10.0", "9.5", true},
+	}
+	for _, c := range cases {
+		if got := versionAtLeast(c.a, c.b); got != c.want {
+			t.Errorf("versionAtLeast(%s, %s) = %v", c.a, c.b, got)
+		}
+	}
+}
+
+func TestSqlite3_Detect(t *testing.T) {
+	db, _ := sql.Open("sqlite3", ":memory:")
+	defer db.Close()
+	dial, err := (&sqlite3{}).Detect(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caps := dial.Capabilities()
+	if caps.Version == "" || !caps.Savepoints {
+		t.Fatal("failed to detect sqlite3 capabilities", caps)
+	}
+	old := (&sqlite3{version: "3.22.0"}).Capabilities()
+	if old.OnConflict || old.RenameColumn || old.DropColumn {
+		t.Fatal("sqlite3 3.22.0 supports neither upsert nor rename/drop column", old)
+	}
+	if caps := (&sqlite3{version: "3.35.5"}).Capabilities(); !caps.DropColumn || !caps.Returning {
+		t.Fatal("sqlite3 3.35.5 supports drop column and returning", caps)
+	}
+}
+
+func TestSqlite3_DataSource(t *testing.T) {
+	cases := []struct{ source, want string }{
+		{"test.db", "test.db?_foreign_keys=1"},
+		{"file:test.db?cache=shared", "file:test.db?cache=shared&_foreign_keys=1"},
+		{"test.db?_foreign_keys=0", "test.db?_foreign_keys=0"},
+		{"test.db?_fk=false", "test.db?_fk=false"},
+	}
+	for _, c := range cases {
+		if got := (&sqlite3{}).DataSource(c.source); got != c.want {
+			t.Errorf("DataSource(%s) = %s, want %s", c.source, got, c.want)
+		}
+	}
+}
+
+func TestSqlite3_TableObjects(t *testing.T) {
+	db, _ := sql.Open("sqlite3", ":memory:")
+	defer db.Close()
+	for _, stmt := range []string{
+		`CREATE TABLE "User" (ID integer)`,
+		`CREATE TABLE UserProfile (UserID integer)`,
+		`CREATE VIEW v_user AS SELECT ID FROM "User"`,
+		`CREATE VIEW v_profile AS SELECT UserID FROM UserProfile`,
+		`CREATE VIEW v_both AS SELECT p.UserID FROM user u JOIN UserProfile p ON p.UserID = u.ID`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	objects, err := (&sqlite3{}).TableObjects(context.Background(), db, "User")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var views []string
+	for _, obj := range objects {
+		views = append(views, obj.Name)
+	}
+	//v_profile只引用了UserProfile
+	if !reflect.DeepEqual(views, []string{"v_both", "v_user"}) {
+		t.Fatal("unexpected views depending on User", views)
+	}
+}
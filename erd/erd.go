@@ -0,0 +1,249 @@
+package erd
+
+import (
+	"fmt"
+	"html"
+	"myorm"
+	"myorm/dialect"
+	"myorm/schema"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//生成实体关系图（ER图）：表、列、类型、主键和外键的连线，输出Graphviz的DOT或Mermaid。
+//表结构可以来自模型（schema.Parse）或数据库（Inspect），两者都先转换成dialect.TableInfo，
+//迁移之后从模型和从数据库生成的图相同。输出按表名排序，同样的表结构总是得到同样的图
+
+//把模型的表框架转换成表结构
+func FromSchemas(schemas ...*schema.Schema) []*dialect.TableInfo {
+	var tables []*dialect.TableInfo
+	for _, s := range schemas {
+		info := &dialect.TableInfo{Name: s.Name}
+		for _, f := range s.Fields {
+			info.Columns = append(info.Columns, dialect.ColumnInfo{
+				Name: f.Name, Type: f.Type, NotNull: f.IsNotNull(), Default: f.DefaultValue(), PrimaryKey: f.IsPrimaryKey(),
+			})
+			if f.IsUnique() && !f.IsPrimaryKey() {
+				info.Indexes = append(info.Indexes, dialect.IndexInfo{Unique: true, Constraint: true, Columns: []string{f.Name}})
+			}
+		}
+		for _, idx := range s.Indexes {
+			info.Indexes = append(info.Indexes, dialect.IndexInfo{Name: idx.Name, Unique: idx.Unique, Partial: idx.Where != "", Columns: idx.Columns})
+		}
+		for _, fk := range s.ForeignKeys {
+			info.ForeignKeys = append(info.ForeignKeys, dialect.ForeignKeyInfo{
+				Name: fk.Name, Columns: fk.Columns, RefTable: fk.RefTable, RefColumns: fk.RefColumns, OnUpdate: fk.OnUpdate, OnDelete: fk.OnDelete,
+			})
+		}
+		tables = append(tables, info)
+	}
+	return tables
+}
+
+//读取数据库中全部表的结构
+func Inspect(engine *myorm.Engine) ([]*dialect.TableInfo, error) {
+	names, err := engine.Tables()
+	if err != nil {
+		return nil, err
+	}
+	var tables []*dialect.TableInfo
+	for _, name := range names {
+		info, err := engine.Inspect(name)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, info)
+	}
+	return tables, nil
+}
+
+// relation 一条外键连线，从引用的表指向被引用的表
+type relation struct {
+	from, to         string
+	columns, refCols []string
+	nullable         bool //外键列可以为NULL：引用是可选的
+	unique           bool //外键列唯一：一对一
+}
+
+type diagram struct {
+	tables    []*dialect.TableInfo
+	byName    map[string]*dialect.TableInfo
+	relations []relation
+	fkColumns map[string]map[string]bool //表名→外键列
+}
+
+func newDiagram(tables []*dialect.TableInfo) *diagram {
+	d := &diagram{byName: make(map[string]*dialect.TableInfo), fkColumns: make(map[string]map[string]bool)}
+	d.tables = append(d.tables, tables...)
+	sort.SliceStable(d.tables, func(i, j int) bool { return d.tables[i].Name < d.tables[j].Name })
+	for _, t := range d.tables {
+		d.byName[t.Name] = t
+	}
+	for _, t := range d.tables {
+		d.fkColumns[t.Name] = make(map[string]bool)
+		for _, fk := range t.ForeignKeys {
+			r := relation{from: t.Name, to: fk.RefTable, columns: fk.Columns, refCols: d.refColumns(fk)}
+			for _, col := range t.Columns {
+				for _, c := range fk.Columns {
+					if col.Name == c {
+						r.nullable = r.nullable || !col.NotNull && !col.PrimaryKey
+						d.fkColumns[t.Name][c] = true
+					}
+				}
+			}
+			r.unique = isUnique(t, fk.Columns)
+			d.relations = append(d.relations, r)
+		}
+	}
+	return d
+}
+
+//SQLite引用主键时可以省略列名，从被引用的表中找出主键
+func (d *diagram) refColumns(fk dialect.ForeignKeyInfo) []string {
+	cols := append([]string(nil), fk.RefColumns...)
+	if ref, ok := d.byName[fk.RefTable]; ok {
+		var pk []string
+		for _, col := range ref.Columns {
+			if col.PrimaryKey {
+				pk = append(pk, col.Name)
+			}
+		}
+		for i := range cols {
+			if cols[i] == "" && i < len(pk) {
+				cols[i] = pk[i]
+			}
+		}
+	}
+	return cols
+}
+
+//columns是否恰好是主键或一个非部分唯一索引的列
+func isUnique(t *dialect.TableInfo, columns []string) bool {
+	same := func(a []string) bool {
+		if len(a) != len(columns) {
+			return false
+		}
+		set := make(map[string]bool)
+		for _, c := range a {
+			set[c] = true
+		}
+		for _, c := range columns {
+			if !set[c] {
+				return false
+			}
+		}
+		return true
+	}
+	var pk []string
+	for _, col := range t.Columns {
+		if col.PrimaryKey {
+			pk = append(pk, col.Name)
+		}
+	}
+	if same(pk) {
+		return true
+	}
+	for _, idx := range t.Indexes {
+		if idx.Unique && !idx.Partial && same(idx.Columns) {
+			return true
+		}
+	}
+	return false
+}
+
+//Graphviz的DOT：每个表是一个HTML表格，外键从列连到被引用的列
+func DOT(tables []*dialect.TableInfo) string {
+	d := newDiagram(tables)
+	var b strings.Builder
+	b.WriteString("digraph ER {\n\trankdir=LR;\n\tnode [shape=plaintext, fontname=\"Helvetica\"];\n\tedge [arrowhead=crow, arrowtail=none, dir=both];\n")
+	for _, t := range d.tables {
+		fmt.Fprintf(&b, "\t%s [label=<<table border=\"0\" cellborder=\"1\" cellspacing=\"0\" cellpadding=\"4\">\n", dotID(t.Name))
+		fmt.Fprintf(&b, "\t\t<tr><td colspan=\"2\" bgcolor=\"lightgrey\"><b>%s</b></td></tr>\n", html.EscapeString(t.Name))
+		for _, col := range t.Columns {
+			name := html.EscapeString(col.Name)
+			if col.PrimaryKey {
+				name = "<u>" + name + "</u>"
+			}
+			fmt.Fprintf(&b, "\t\t<tr><td port=%s align=\"left\">%s%s</td><td align=\"left\">%s</td></tr>\n",
+				dotID(col.Name), name, d.keys(t, col, " (", ")"), html.EscapeString(col.Type))
+		}
+		b.WriteString("\t</table>>];\n")
+	}
+	for _, r := range d.relations {
+		from, to := dotID(r.from)+":"+dotID(r.columns[0]), dotID(r.to)
+		if _, ok := d.byName[r.to]; ok && len(r.refCols) > 0 && r.refCols[0] != "" {
+			to += ":" + dotID(r.refCols[0])
+		}
+		arrow := "crow"
+		if r.unique {
+			arrow = "tee"
+		}
+		tail := "tee"
+		if r.nullable {
+			tail = "odot"
+		}
+		fmt.Fprintf(&b, "\t%s -> %s [label=%s, arrowhead=%s, arrowtail=%s];\n", to, from, dotID(strings.Join(r.columns, ", ")), arrow, tail)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+//列的键：PK、FK、UK
+func (d *diagram) keys(t *dialect.TableInfo, col dialect.ColumnInfo, prefix, suffix string) string {
+	var keys []string
+	if col.PrimaryKey {
+		keys = append(keys, "PK")
+	}
+	if d.fkColumns[t.Name][col.Name] {
+		keys = append(keys, "FK")
+	}
+	if !col.PrimaryKey && isUnique(t, []string{col.Name}) {
+		keys = append(keys, "UK")
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	return prefix + strings.Join(keys, ", ") + suffix
+}
+
+func dotID(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+//Mermaid的erDiagram，可以直接放在Markdown中
+func Mermaid(tables []*dialect.TableInfo) string {
+	d := newDiagram(tables)
+	var b strings.Builder
+	b.WriteString("erDiagram\n")
+	for _, t := range d.tables {
+		fmt.Fprintf(&b, "    %s {\n", mermaidID(t.Name))
+		for _, col := range t.Columns {
+			typ := mermaidID(col.Type)
+			if col.Type == "" {
+				typ = "_"
+			}
+			fmt.Fprintf(&b, "        %s %s%s\n", typ, mermaidID(col.Name), d.keys(t, col, " ", ""))
+		}
+		b.WriteString("    }\n")
+	}
+	for _, r := range d.relations {
+		//左边是被引用的表：||恰好一个，|o零个或一个；右边是引用的表：o{零个或多个，o|零个或一个
+		left, right := "||", "o{"
+		if r.nullable {
+			left = "|o"
+		}
+		if r.unique {
+			right = "o|"
+		}
+		fmt.Fprintf(&b, "    %s %s--%s %s : %q\n", mermaidID(r.to), left, right, mermaidID(r.from), strings.Join(r.columns, ", "))
+	}
+	return b.String()
+}
+
+var mermaidInvalid = regexp.MustCompile(`[^\w()\[\]-]`)
+
+//Mermaid的实体名、属性名和类型只能包含字母、数字、下划线、横线和括号，其余字符换成下划线
+func mermaidID(s string) string {
+	return mermaidInvalid.ReplaceAllString(s, "_")
+}
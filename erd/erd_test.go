@@ -0,0 +1,78 @@
+package erd
+
+import (
+	"flag"
+	"io/ioutil"
+	"myorm"
+	"myorm/dialect"
+	"myorm/schema"
+	"path/filepath"
+	"testing"
+)
+
+//go test ./erd -update 重新生成testdata下的golden文件
+var update = flag.Bool("update", false, "update golden files")
+
+type Customer struct {
+	ID    int64  `myorm:"PRIMARY KEY"`
+	Email string `myorm:"NOT NULL UNIQUE"`
+}
+
+type Invoice struct {
+	ID         int64 `myorm:"PRIMARY KEY"`
+	CustomerID int64 `myorm:"NOT NULL;references:Customer(ID);index"`
+	Total      float64
+}
+
+//每个客户最多一份资料，客户可以为空
+type Profile struct {
+	ID         int64 `myorm:"PRIMARY KEY"`
+	CustomerID int64 `myorm:"references:Customer(ID);uniqueIndex"`
+	Bio        string
+}
+
+var models = []interface{}{&Profile{}, &Invoice{}, &Customer{}}
+
+func checkGolden(t *testing.T, name string, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := ioutil.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Fatalf("diagram mismatch for %s\ngot:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+func TestDiagram(t *testing.T) {
+	d, _ := dialect.GetDialect("sqlite3")
+	var schemas []*schema.Schema
+	for _, model := range models {
+		schemas = append(schemas, schema.Parse(model, d))
+	}
+	tables := FromSchemas(schemas...)
+	checkGolden(t, "shop.dot", DOT(tables))
+	checkGolden(t, "shop.mmd", Mermaid(tables))
+
+	//迁移之后从数据库生成的图与从模型生成的相同
+	engine, err := myorm.NewEngine("sqlite3", filepath.Join(t.TempDir(), "shop.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+	if err := engine.Migrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	inspected, err := Inspect(engine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "shop.dot", DOT(inspected))
+	checkGolden(t, "shop.mmd", Mermaid(inspected))
+}
@@ -0,0 +1,311 @@
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"myorm"
+	"myorm/dialect"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//根据已有数据库的表结构生成带myorm注解的结构体，用于接手遗留的数据库。
+//列可以为NULL时字段是指针类型；名字转换后与表名、列名不同时生成TableName()方法和column:注解，
+//生成的结构体用Migrate()迁移时不会有修改。
+
+// Options 生成代码的选项
+type Options struct {
+	Package    string   //包名，默认为models
+	Tables     []string //只生成这些表，为空时生成全部表
+	TableName  Naming   //表名→结构体名，默认为CamelCase
+	ColumnName Naming   //列名→字段名，默认为CamelCase
+}
+
+//读取engine连接的数据库中的表结构，生成Go源文件
+func Generate(engine *myorm.Engine, opts Options) ([]byte, error) {
+	names := opts.Tables
+	if len(names) == 0 {
+		var err error
+		if names, err = engine.Tables(); err != nil {
+			return nil, err
+		}
+	}
+	var tables []*dialect.TableInfo
+	for _, name := range names {
+		info, err := engine.Inspect(name)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, info)
+	}
+	return Source(engine.Dialect(), tables, opts)
+}
+
+//用d把表结构转换成Go源文件，d用来判断是否需要type:注解。结构体按表名排序
+func Source(d dialect.Dialect, tables []*dialect.TableInfo, opts Options) ([]byte, error) {
+	if opts.Package == "" {
+		opts.Package = "models"
+	}
+	if opts.TableName == nil {
+		opts.TableName = CamelCase
+	}
+	if opts.ColumnName == nil {
+		opts.ColumnName = CamelCase
+	}
+	tables = append([]*dialect.TableInfo(nil), tables...)
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Name < tables[j].Name })
+	g := &generator{d: d, opts: opts, tables: make(map[string]*dialect.TableInfo)}
+	for _, table := range tables {
+		g.tables[table.Name] = table
+	}
+
+	var body bytes.Buffer
+	structNames := make(map[string]bool)
+	for _, table := range tables {
+		g.writeStruct(&body, table, unique(opts.TableName(table.Name), structNames))
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by myorm gen. DO NOT EDIT.\n\npackage %s\n\n", opts.Package)
+	if g.imports {
+		buf.WriteString("import \"time\"\n\n")
+	}
+	buf.Write(body.Bytes())
+	return format.Source(buf.Bytes())
+}
+
+type generator struct {
+	d       dialect.Dialect
+	opts    Options
+	tables  map[string]*dialect.TableInfo
+	imports bool //用到了time.Time
+}
+
+//一个字段的注解，依次为列名、类型等关键字，列约束，索引和外键
+type fieldTag struct {
+	keywords   []string
+	primaryKey bool
+	notNull    bool
+	unique     bool
+	dflt       *string
+	indexes    []string
+}
+
+func (t *fieldTag) String() string {
+	var parts, constraints []string
+	parts = append(parts, t.keywords...)
+	if t.primaryKey {
+		constraints = append(constraints, "PRIMARY KEY")
+	}
+	if t.notNull {
+		constraints = append(constraints, "NOT NULL")
+	}
+	if t.unique {
+		constraints = append(constraints, "UNIQUE")
+	}
+	if t.dflt != nil {
+		constraints = append(constraints, "DEFAULT "+*t.dflt)
+	}
+	if len(constraints) > 0 {
+		parts = append(parts, strings.Join(constraints, " "))
+	}
+	parts = append(parts, t.indexes...)
+	if len(parts) == 0 {
+		return ""
+	}
+	tag := "myorm:" + strconv.Quote(strings.Join(parts, ";"))
+	if strings.Contains(tag, "`") {
+		return " " + strconv.Quote(tag)
+	}
+	return " `" + tag + "`"
+}
+
+func (g *generator) writeStruct(buf *bytes.Buffer, table *dialect.TableInfo, name string) {
+	tags := make(map[string]*fieldTag)
+	for _, col := range table.Columns {
+		tags[col.Name] = &fieldTag{}
+	}
+	var notes []string
+	g.primaryKey(table, tags)
+	notes = append(notes, g.indexes(table, tags)...)
+	notes = append(notes, g.foreignKeys(table, tags)...)
+
+	fmt.Fprintf(buf, "// %s 对应表%s\n", name, table.Name)
+	for _, note := range notes {
+		fmt.Fprintf(buf, "// %s\n", note)
+	}
+	fmt.Fprintf(buf, "type %s struct {\n", name)
+	fieldNames := make(map[string]bool)
+	for _, col := range table.Columns {
+		tag := tags[col.Name]
+		field := unique(g.opts.ColumnName(col.Name), fieldNames)
+		if field != col.Name {
+			tag.keywords = append([]string{"column:" + col.Name}, tag.keywords...)
+		}
+		typ, zero := goType(col.Type)
+		//SQLite中没有声明类型的列对应[]byte，迁移时视同默认的blob，不写type:注解
+		if col.Type != "" && !strings.EqualFold(strings.TrimSpace(col.Type), g.d.DataTypeOf(reflect.ValueOf(zero))) {
+			tag.keywords = append(tag.keywords, "type:"+col.Type)
+		}
+		tag.notNull, tag.dflt = col.NotNull, col.Default
+		if !col.NotNull && !col.PrimaryKey && typ != "[]byte" {
+			typ = "*" + typ
+		}
+		if strings.Contains(typ, "time.") {
+			g.imports = true
+		}
+		fmt.Fprintf(buf, "\t%s %s%s\n", field, typ, tag)
+	}
+	buf.WriteString("}\n\n")
+	if name != table.Name {
+		fmt.Fprintf(buf, "func (%s) TableName() string { return %s }\n\n", name, strconv.Quote(table.Name))
+	}
+}
+
+//主键的每一列都写上PRIMARY KEY，多于一列时建表语句把它们合成表约束PRIMARY KEY (a, b)
+func (g *generator) primaryKey(table *dialect.TableInfo, tags map[string]*fieldTag) {
+	for _, col := range table.Columns {
+		if col.PrimaryKey {
+			tags[col.Name].primaryKey = true
+		}
+	}
+}
+
+//单列的UNIQUE约束写成列约束，其余索引写成index、uniqueIndex注解。
+//部分索引的条件读不出来，不生成，只在结构体的注释中说明
+func (g *generator) indexes(table *dialect.TableInfo, tags map[string]*fieldTag) (notes []string) {
+	for _, idx := range table.Indexes {
+		switch {
+		case idx.Primary:
+			continue
+		case idx.Partial:
+			notes = append(notes, fmt.Sprintf("partial index %s on (%s) is not generated", idx.Name, strings.Join(idx.Columns, ", ")))
+			continue
+		case idx.Constraint && len(idx.Columns) == 1:
+			tags[idx.Columns[0]].unique = true
+			continue
+		}
+		name := idx.Name
+		if idx.Constraint {
+			//UNIQUE约束自动建立的索引（如sqlite_autoindex_*）不能用原来的名字建立
+			name = "uk_" + table.Name + "_" + strings.Join(idx.Columns, "_")
+		}
+		keyword := "index"
+		if idx.Unique {
+			keyword = "uniqueIndex"
+		}
+		for i, col := range idx.Columns {
+			tag, ok := tags[col]
+			if !ok {
+				notes = append(notes, fmt.Sprintf("index %s on expression is not generated", idx.Name))
+				break
+			}
+			opt := keyword
+			if len(idx.Columns) > 1 {
+				opt += fmt.Sprintf(":%s,priority:%d", name, i+1)
+			} else if name != "idx_"+table.Name+"_"+col {
+				opt += ":" + name
+			}
+			tag.indexes = append(tag.indexes, opt)
+		}
+	}
+	return
+}
+
+var wordRegexp = regexp.MustCompile(`^\w+$`)
+
+//外键写成references注解，多列外键用相同的foreignKey名字合并；引用主键时省略的列名从被引用的表中查找
+func (g *generator) foreignKeys(table *dialect.TableInfo, tags map[string]*fieldTag) (notes []string) {
+	for _, fk := range table.ForeignKeys {
+		refColumns := append([]string(nil), fk.RefColumns...)
+		if ref, ok := g.tables[fk.RefTable]; ok {
+			var pk []string
+			for _, col := range ref.Columns {
+				if col.PrimaryKey {
+					pk = append(pk, col.Name)
+				}
+			}
+			for i := range refColumns {
+				if refColumns[i] == "" && i < len(pk) {
+					refColumns[i] = pk[i]
+				}
+			}
+		}
+		valid := wordRegexp.MatchString(fk.RefTable)
+		for _, col := range refColumns {
+			valid = valid && wordRegexp.MatchString(col)
+		}
+		if !valid {
+			notes = append(notes, fmt.Sprintf("foreign key (%s) references %s is not generated", strings.Join(fk.Columns, ", "), fk.RefTable))
+			continue
+		}
+		name := fk.Name
+		if name == "" && len(fk.Columns) > 1 {
+			name = "fk_" + table.Name + "_" + fk.Columns[0]
+		}
+		for i, col := range fk.Columns {
+			tag := tags[col]
+			if name != "" && name != "fk_"+table.Name+"_"+col {
+				tag.indexes = append(tag.indexes, "foreignKey:"+name)
+			}
+			tag.indexes = append(tag.indexes, fmt.Sprintf("references:%s(%s)", fk.RefTable, refColumns[i]))
+			var actions []string
+			if a := fk.OnDelete; i == 0 && a != "" && a != "NO ACTION" {
+				actions = append(actions, "OnDelete:"+a)
+			}
+			if a := fk.OnUpdate; i == 0 && a != "" && a != "NO ACTION" {
+				actions = append(actions, "OnUpdate:"+a)
+			}
+			if len(actions) > 0 {
+				tag.indexes = append(tag.indexes, "constraint:"+strings.Join(actions, ","))
+			}
+		}
+	}
+	return
+}
+
+//按声明的类型选择Go类型，规则与SQLite的类型亲和性相似，返回类型名和它的零值
+func goType(typ string) (string, interface{}) {
+	t := strings.ToLower(typ)
+	has := func(words ...string) bool {
+		for _, w := range words {
+			if strings.Contains(t, w) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case t == "":
+		return "[]byte", []byte(nil)
+	case has("bool") || t == "tinyint(1)" || t == "bit":
+		return "bool", false
+	case has("date", "time"):
+		return "time.Time", time.Time{}
+	case has("bigint", "bigserial", "int8"):
+		return "int64", int64(0)
+	case has("int", "serial"):
+		return "int", 0
+	case has("char", "clob", "text", "uuid", "json"):
+		return "string", ""
+	case has("blob", "bytea", "binary"):
+		return "[]byte", []byte(nil)
+	case has("real", "floa", "doub", "numeric", "decimal"):
+		return "float64", float64(0)
+	}
+	return "string", ""
+}
+
+//名字重复时加上数字后缀
+func unique(name string, used map[string]bool) string {
+	result := name
+	for i := 2; used[result]; i++ {
+		result = name + strconv.Itoa(i)
+	}
+	used[result] = true
+	return result
+}
@@ -0,0 +1,122 @@
+package gen
+
+import (
+	"flag"
+	"io/ioutil"
+	"myorm"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//go test ./gen -update 重新生成testdata下的golden文件
+var update = flag.Bool("update", false, "update golden files")
+
+func legacyDB(t *testing.T) *myorm.Engine {
+	t.Helper()
+	engine, err := myorm.NewEngine("sqlite3", filepath.Join(t.TempDir(), "legacy.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(engine.Close)
+	for _, stmt := range []string{
+		"CREATE TABLE user_account (user_id INTEGER PRIMARY KEY, e_mail varchar(255) NOT NULL UNIQUE, nick_name TEXT, age INTEGER DEFAULT 0, created_at DATETIME)",
+		"CREATE INDEX idx_user_nick ON user_account (nick_name, age)",
+		"CREATE TABLE order_item (order_id INTEGER NOT NULL, line INTEGER NOT NULL, user_id INTEGER REFERENCES user_account ON DELETE CASCADE, price REAL, note, PRIMARY KEY (order_id, line))",
+		"CREATE INDEX idx_item_cheap ON order_item (price) WHERE price < 10",
+	} {
+		if _, err := engine.NewSession().Raw(stmt).Exec(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return engine
+}
+
+func TestGenerate(t *testing.T) {
+	engine := legacyDB(t)
+	src, err := Generate(engine, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join("testdata", "legacy.golden")
+	if *update {
+		if err := ioutil.WriteFile(path, src, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(src) != string(want) {
+		t.Fatalf("generated code mismatch\ngot:\n%s\nwant:\n%s", src, want)
+	}
+}
+
+//与testdata/legacy.golden中生成的结构体相同
+type UserAccount struct {
+	UserID    int        `myorm:"column:user_id;PRIMARY KEY"`
+	EMail     string     `myorm:"column:e_mail;type:varchar(255);NOT NULL UNIQUE"`
+	NickName  *string    `myorm:"column:nick_name;index:idx_user_nick,priority:1"`
+	Age       *int       `myorm:"column:age;DEFAULT 0;index:idx_user_nick,priority:2"`
+	CreatedAt *time.Time `myorm:"column:created_at"`
+}
+
+func (UserAccount) TableName() string { return "user_account" }
+
+type OrderItem struct {
+	OrderID int      `myorm:"column:order_id;PRIMARY KEY NOT NULL"`
+	Line    int      `myorm:"column:line;PRIMARY KEY NOT NULL"`
+	UserID  *int     `myorm:"column:user_id;references:user_account(user_id);constraint:OnDelete:CASCADE"`
+	Price   *float64 `myorm:"column:price"`
+	Note    []byte   `myorm:"column:note"`
+}
+
+func (OrderItem) TableName() string { return "order_item" }
+
+func TestGenerate_RoundTrip(t *testing.T) {
+	engine := legacyDB(t)
+	plan, err := engine.MigratePlan(&UserAccount{}, &OrderItem{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Statements) != 0 {
+		t.Fatalf("generated model should match the table:\n%s", plan)
+	}
+	//NULL读成nil指针
+	age := 18
+	s := engine.NewSession().Model(&UserAccount{})
+	if _, err := s.Insert(&UserAccount{UserID: 1, EMail: "a@b.c", Age: &age}); err != nil {
+		t.Fatal(err)
+	}
+	var users []UserAccount
+	if err := s.Find(&users); err != nil || len(users) != 1 || users[0].NickName != nil || *users[0].Age != 18 {
+		t.Fatal("failed to read nullable columns", users, err)
+	}
+	//联合主键：Save按全部主键列更新
+	items := engine.NewSession().Model(&OrderItem{})
+	if _, err := items.Insert(&OrderItem{OrderID: 1, Line: 1}, &OrderItem{OrderID: 1, Line: 2}); err != nil {
+		t.Fatal(err)
+	}
+	price := 9.5
+	if n, err := items.Save(&OrderItem{OrderID: 1, Line: 2, Price: &price}); err != nil || n != 1 {
+		t.Fatal("failed to save by composite primary key", n, err)
+	}
+	if _, err := items.Insert(&OrderItem{OrderID: 1, Line: 2}); err == nil {
+		t.Fatal("composite primary key should reject duplicates")
+	}
+}
+
+func TestNaming(t *testing.T) {
+	for name, want := range map[string][2]string{
+		"user_id":      {"UserID", "User_id"},
+		"order-items":  {"OrderItems", "Order_items"},
+		"createdAt":    {"CreatedAt", "CreatedAt"},
+		"2fa":          {"X2fa", "X2fa"},
+		"http_url_api": {"HTTPURLAPI", "Http_url_api"},
+	} {
+		if got := [2]string{CamelCase(name), Exported(name)}; got != want {
+			t.Fatal("unexpected names of", name, got)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package gen
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Naming 把数据库中的表名、列名转换成Go的标识符，结果须是导出的合法标识符
+type Naming func(name string) string
+
+//转换成驼峰式时整体大写的缩写
+var initialisms = map[string]bool{
+	"ID": true, "URL": true, "URI": true, "API": true, "HTTP": true, "JSON": true,
+	"SQL": true, "UUID": true, "IP": true, "UID": true, "XML": true, "HTML": true,
+}
+
+//按下划线、横线、空格、点分词后转换成驼峰式，常见缩写整体大写：user_id → UserID，order-items → OrderItems
+func CamelCase(name string) string {
+	var b strings.Builder
+	words := strings.FieldsFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	for _, word := range words {
+		if upper := strings.ToUpper(word); initialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		r := []rune(word)
+		b.WriteString(string(unicode.ToUpper(r[0])) + string(r[1:]))
+	}
+	return identifier(b.String())
+}
+
+//保持原来的名字，只把首字母大写、非法字符换成下划线：user_id → User_id
+func Exported(name string) string {
+	r := []rune(name)
+	for i := range r {
+		if !unicode.IsLetter(r[i]) && !unicode.IsDigit(r[i]) && r[i] != '_' {
+			r[i] = '_'
+		}
+	}
+	if len(r) > 0 {
+		r[0] = unicode.ToUpper(r[0])
+	}
+	return identifier(string(r))
+}
+
+//不是以大写字母开头的（如数字、中文、空字符串）加上前缀X
+func identifier(name string) string {
+	for _, r := range name {
+		if unicode.IsUpper(r) {
+			return name
+		}
+		break
+	}
+	return "X" + name
+}
@@ -1,5 +1,5 @@
 module myorm
 
-go 1.16
+go 1.21
 
-require github.com/mattn/go-sqlite3 v2.0.3+incompatible
\ No newline at end of file
+require github.com/mattn/go-sqlite3 v2.0.3+incompatible
@@ -0,0 +1,199 @@
+// Package fakedb 是一个只记录SQL语句、不连接真实数据库的 database/sql 驱动，
+// 用于在没有MySQL、PostgreSQL服务器的环境中测试各个方言生成的SQL语句。
+//
+// 用法：
+//	db, rec := fakedb.Open()
+//	s := session.New(db, dial)
+//	_ = s.CreateTable()
+//	rec.Statements() // 已执行的SQL语句
+package fakedb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Statement 一条被执行的SQL语句及其参数
+type Statement struct {
+	Query string
+	Args  []interface{}
+}
+
+func (s Statement) String() string {
+	if len(s.Args) == 0 {
+		return s.Query
+	}
+	return fmt.Sprintf("%s %v", s.Query, s.Args)
+}
+
+// Recorder 记录一个数据库上执行的所有语句。
+// Rows 不为nil时决定查询语句返回的结果，否则查询返回空结果。
+type Recorder struct {
+	mu    sync.Mutex
+	stmts []Statement
+	Rows  func(query string, args []interface{}) (columns []string, rows [][]interface{})
+}
+
+// Statements 返回已执行的语句
+func (r *Recorder) Statements() []Statement {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Statement(nil), r.stmts...)
+}
+
+// Queries 返回已执行的语句（不含参数），每条一行
+func (r *Recorder) Queries() string {
+	var lines []string
+	for _, s := range r.Statements() {
+		lines = append(lines, strings.TrimSpace(s.Query))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Reset 清空已记录的语句
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stmts = nil
+}
+
+func (r *Recorder) record(query string, args []driver.NamedValue) []interface{} {
+	vars := make([]interface{}, len(args))
+	for i, a := range args {
+		vars[i] = a.Value
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stmts = append(r.stmts, Statement{Query: query, Args: vars})
+	return vars
+}
+
+var (
+	registerOnce sync.Once
+	seq          int64
+	recorders    sync.Map // dsn -> *Recorder
+)
+
+// Open 打开一个新的假数据库
+func Open() (*sql.DB, *Recorder) {
+	registerOnce.Do(func() { sql.Register("fakedb", fakeDriver{}) })
+	dsn := fmt.Sprintf("fakedb-%d", atomic.AddInt64(&seq, 1))
+	rec := &Recorder{}
+	recorders.Store(dsn, rec)
+	db, err := sql.Open("fakedb", dsn)
+	if err != nil {
+		panic(err)
+	}
+	return db, rec
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	rec, ok := recorders.Load(dsn)
+	if !ok {
+		return nil, fmt.Errorf("fakedb: unknown database %s", dsn)
+	}
+	return &conn{rec: rec.(*Recorder)}, nil
+}
+
+type conn struct {
+	rec *Recorder
+}
+
+var (
+	_ driver.ExecerContext  = (*conn)(nil)
+	_ driver.QueryerContext = (*conn)(nil)
+	_ driver.ConnBeginTx    = (*conn)(nil)
+)
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{c: c, query: query}, nil
+}
+
+func (c *conn) Close() error { return nil }
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.rec.record("BEGIN", nil)
+	return tx{c}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.rec.record(query, args)
+	return driver.RowsAffected(1), nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	vars := c.rec.record(query, args)
+	r := &rows{}
+	if c.rec.Rows != nil {
+		r.columns, r.values = c.rec.Rows(query, vars)
+	}
+	return r, nil
+}
+
+type tx struct{ c *conn }
+
+func (t tx) Commit() error {
+	t.c.rec.record("COMMIT", nil)
+	return nil
+}
+
+func (t tx) Rollback() error {
+	t.c.rec.record("ROLLBACK", nil)
+	return nil
+}
+
+type stmt struct {
+	c     *conn
+	query string
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.c.ExecContext(context.Background(), s.query, named(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.c.QueryContext(context.Background(), s.query, named(args))
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	result := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		result[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return result
+}
+
+type rows struct {
+	columns []string
+	values  [][]interface{}
+	next    int
+}
+
+func (r *rows) Columns() []string { return r.columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.values) {
+		return io.EOF
+	}
+	for i, v := range r.values[r.next] {
+		dest[i] = v
+	}
+	r.next++
+	return nil
+}
@@ -1,47 +1,205 @@
 package log
 
 import (
-	"io/ioutil"
+	"context"
+	"fmt"
+	"io"
 	"log"
 	"os"
 	"sync"
+	"time"
 )
 
-var (
-	errorLog = log.New(os.Stdout, "\033[31m[error]\033[0m ", log.LstdFlags|log.Lshortfile)
-	infoLog  = log.New(os.Stdout, "\033[34m[info ]\033[0m ", log.LstdFlags|log.Lshortfile)
-	loggers  = []*log.Logger{errorLog, infoLog}
-	mu       sync.Mutex
-)
-
-// log methods
-var (
-	Error  = errorLog.Println
-	Errorf = errorLog.Printf
-	Info   = infoLog.Println
-	Infof  = infoLog.Printf
-)
+// Level 日志级别，低于设置级别的日志不输出
+type Level int
 
 // log levels
 const (
-	InfoLevel = iota
-	ErrorLevel
+	DebugLevel Level = iota //每条SQL语句、事务的开始和结束
+	InfoLevel               //迁移等操作的进度
+	WarnLevel
+	ErrorLevel //执行失败的语句和操作
 	Disabled
 )
 
-// SetLevel controls log level
-func SetLevel(level int) {
-	mu.Lock()
-	defer mu.Unlock()
+var levelNames = map[Level]string{DebugLevel: "debug", InfoLevel: "info", WarnLevel: "warn", ErrorLevel: "error"}
+
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
 
-	for _, logger := range loggers {
-		logger.SetOutput(os.Stdout)
+// Trace 执行一条SQL语句的记录
+type Trace struct {
+	SQL      string        //占位符已按方言改写
+	Args     []interface{} //语句的参数
+	Rows     int64         //影响的行数，查询语句为-1
+	Duration time.Duration //执行用时，查询语句不包括读取结果的时间
+	Err      error
+}
+
+// SlowQuery 执行时间超过阈值的语句
+type SlowQuery struct {
+	*Trace
+	Threshold time.Duration //设置的阈值
+	Caller    string        //执行语句的用户代码位置，如/app/user.go:42
+}
+
+// SlowQueryLogger 由Logger选择实现，没有实现时慢查询以Warn级别的文本输出
+type SlowQueryLogger interface {
+	SlowQuery(ctx context.Context, q *SlowQuery)
+}
+
+// Logger 日志接口，引擎和会话可以分别设置自己的Logger（Engine.SetLogger、Session.WithLogger），
+//没有设置时使用Default()。ctx是会话的上下文，可以从中取出请求ID等信息
+type Logger interface {
+	Debugf(ctx context.Context, format string, v ...interface{})
+	Infof(ctx context.Context, format string, v ...interface{})
+	Warnf(ctx context.Context, format string, v ...interface{})
+	Errorf(ctx context.Context, format string, v ...interface{})
+	//每条SQL语句执行之后调用一次
+	Trace(ctx context.Context, t *Trace)
+}
+
+// TextLogger 输出一行一条的文本日志，如：
+//[info ] 2024/01/31 15:04:05 SELECT * FROM User [] | 1.2ms
+type TextLogger struct {
+	mu     sync.Mutex
+	out    *log.Logger
+	level  Level
+	colors bool
+}
+
+//写到w的文本日志，只输出不低于level的日志
+func New(w io.Writer, level Level) *TextLogger {
+	return &TextLogger{out: log.New(w, "", log.LstdFlags), level: level}
+}
+
+//设置输出的最低级别
+func (l *TextLogger) SetLevel(level Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.level = level
+}
+
+//在级别前加上终端颜色
+func (l *TextLogger) WithColors() *TextLogger {
+	l.colors = true
+	return l
+}
+
+var colors = map[Level]string{DebugLevel: "\033[36m", InfoLevel: "\033[34m", WarnLevel: "\033[33m", ErrorLevel: "\033[31m"}
+
+func (l *TextLogger) print(level Level, msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if level < l.level {
+		return
 	}
+	prefix := fmt.Sprintf("[%-5s] ", level)
+	if l.colors {
+		prefix = fmt.Sprintf("%s[%-5s]\033[0m ", colors[level], level)
+	}
+	l.out.SetPrefix(prefix)
+	_ = l.out.Output(2, msg)
+}
+
+func (l *TextLogger) Debugf(ctx context.Context, format string, v ...interface{}) {
+	l.print(DebugLevel, fmt.Sprintf(format, v...))
+}
 
-	if ErrorLevel < level {
-		errorLog.SetOutput(ioutil.Discard)
+func (l *TextLogger) Infof(ctx context.Context, format string, v ...interface{}) {
+	l.print(InfoLevel, fmt.Sprintf(format, v...))
+}
+
+func (l *TextLogger) Warnf(ctx context.Context, format string, v ...interface{}) {
+	l.print(WarnLevel, fmt.Sprintf(format, v...))
+}
+
+func (l *TextLogger) Errorf(ctx context.Context, format string, v ...interface{}) {
+	l.print(ErrorLevel, fmt.Sprintf(format, v...))
+}
+
+//成功的语句为Debug级别，失败的为Error级别
+func (l *TextLogger) Trace(ctx context.Context, t *Trace) {
+	msg := fmt.Sprintf("%s %v | %s", t.SQL, t.Args, t.Duration)
+	if t.Rows >= 0 {
+		msg += fmt.Sprintf(" | %d rows", t.Rows)
 	}
-	if InfoLevel < level {
-		infoLog.SetOutput(ioutil.Discard)
+	if t.Err != nil {
+		l.print(ErrorLevel, msg+" | "+t.Err.Error())
+		return
 	}
-}
\ No newline at end of file
+	l.print(DebugLevel, msg)
+}
+
+//Warn级别
+func (l *TextLogger) SlowQuery(ctx context.Context, q *SlowQuery) {
+	l.print(WarnLevel, formatSlowQuery(q))
+}
+
+//慢查询的文本形式：slow query 语句 参数 | 用时 > 阈值 | 调用位置
+func formatSlowQuery(q *SlowQuery) string {
+	return fmt.Sprintf("slow query %s %v | %s > %s | %s", q.SQL, q.Args, q.Duration, q.Threshold, q.Caller)
+}
+
+//把慢查询写到l：l实现了SlowQueryLogger时交给它，否则以Warn级别输出文本
+func LogSlowQuery(ctx context.Context, l Logger, q *SlowQuery) {
+	if sl, ok := l.(SlowQueryLogger); ok {
+		sl.SlowQuery(ctx, q)
+		return
+	}
+	l.Warnf(ctx, "%s", formatSlowQuery(q))
+}
+
+var (
+	std                  = New(os.Stdout, DebugLevel).WithColors()
+	defaultLogger Logger = std
+	mu            sync.RWMutex
+)
+
+//没有设置Logger的引擎和会话使用的Logger，默认为输出到标准输出的TextLogger
+func Default() Logger {
+	mu.RLock()
+	defer mu.RUnlock()
+	return defaultLogger
+}
+
+//替换默认的Logger
+func SetDefault(l Logger) {
+	mu.Lock()
+	defer mu.Unlock()
+	defaultLogger = l
+}
+
+// SetLevel controls log level
+//设置默认的TextLogger输出的最低级别
+func SetLevel(level Level) {
+	std.SetLevel(level)
+}
+
+// log methods
+//写到默认的Logger，用于没有引擎的地方
+func Error(v ...interface{}) {
+	Default().Errorf(context.Background(), "%s", sprintln(v...))
+}
+
+func Errorf(format string, v ...interface{}) {
+	Default().Errorf(context.Background(), format, v...)
+}
+
+func Info(v ...interface{}) {
+	Default().Infof(context.Background(), "%s", sprintln(v...))
+}
+
+func Infof(format string, v ...interface{}) {
+	Default().Infof(context.Background(), format, v...)
+}
+
+//与fmt.Sprintln相同（参数之间总是有空格），但末尾没有换行
+func sprintln(v ...interface{}) string {
+	s := fmt.Sprintln(v...)
+	return s[:len(s)-1]
+}
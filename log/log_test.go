@@ -0,0 +1,89 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTextLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, InfoLevel)
+	ctx := context.Background()
+	l.Debugf(ctx, "hidden")
+	l.Infof(ctx, "migrated %d tables", 2)
+	l.Trace(ctx, &Trace{SQL: "SELECT 1", Rows: -1})
+	l.Trace(ctx, &Trace{SQL: "DELETE FROM User WHERE ID = ?", Args: []interface{}{1}, Rows: 0, Duration: time.Millisecond, Err: errors.New("no such table: User")})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", buf.String())
+	}
+	if !strings.HasPrefix(lines[0], "[info ] ") || !strings.HasSuffix(lines[0], " migrated 2 tables") {
+		t.Fatal("unexpected info line", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "[error] ") || !strings.HasSuffix(lines[1], " DELETE FROM User WHERE ID = ? [1] | 1ms | 0 rows | no such table: User") {
+		t.Fatal("unexpected trace line", lines[1])
+	}
+}
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewSlog(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	l.Trace(context.Background(), &Trace{SQL: "UPDATE User SET Age = ?", Args: []interface{}{18}, Rows: 3, Duration: 2 * time.Millisecond})
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record["level"] != "DEBUG" || record["msg"] != "sql" || record["sql"] != "UPDATE User SET Age = ?" ||
+		record["rows"] != float64(3) || record["duration"] != float64(2*time.Millisecond) {
+		t.Fatal("unexpected record", buf.String())
+	}
+	if args, ok := record["args"].([]interface{}); !ok || len(args) != 1 || args[0] != float64(18) {
+		t.Fatal("unexpected args", record["args"])
+	}
+	if _, ok := record["error"]; ok {
+		t.Fatal("successful statement should not have error", buf.String())
+	}
+}
+
+// warnLogger 没有实现SlowQueryLogger的Logger
+type warnLogger struct {
+	Logger
+	warnings []string
+}
+
+func (l *warnLogger) Warnf(ctx context.Context, format string, v ...interface{}) {
+	l.warnings = append(l.warnings, fmt.Sprintf(format, v...))
+}
+
+func TestLogSlowQuery(t *testing.T) {
+	q := &SlowQuery{
+		Trace:     &Trace{SQL: "SELECT * FROM User WHERE Name = ?", Args: []interface{}{"Tom"}, Rows: -1, Duration: 300 * time.Millisecond},
+		Threshold: 200 * time.Millisecond,
+		Caller:    "/app/user.go:42",
+	}
+	l := &warnLogger{}
+	LogSlowQuery(context.Background(), l, q)
+	if len(l.warnings) != 1 || l.warnings[0] != "slow query SELECT * FROM User WHERE Name = ? [Tom] | 300ms > 200ms | /app/user.go:42" {
+		t.Fatalf("unexpected warnings %q", l.warnings)
+	}
+
+	var buf bytes.Buffer
+	LogSlowQuery(context.Background(), NewSlog(slog.New(slog.NewJSONHandler(&buf, nil))), q)
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record["level"] != "WARN" || record["msg"] != "slow query" || record["caller"] != "/app/user.go:42" ||
+		record["threshold"] != float64(200*time.Millisecond) {
+		t.Fatal("unexpected record", buf.String())
+	}
+}
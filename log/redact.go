@@ -0,0 +1,46 @@
+package log
+
+//写日志之前处理语句的参数，避免密码、令牌等敏感数据进入日志：
+//模型中注解含sensitive的字段和用session.Sensitive包装的参数显示为Masked，
+//SetRedactor设置的函数可以再改写全部参数，SetPlaceholdersOnly(true)时日志中只有带占位符的语句，没有参数。
+//这些设置是全局的，对所有Logger（包括慢查询的回调）生效
+
+// Masked 敏感参数在日志中的替代值
+const Masked = "***"
+
+// Redactor 改写写到日志中的参数，args是副本，可以直接修改后返回
+type Redactor func(sql string, args []interface{}) []interface{}
+
+var (
+	redactor         Redactor
+	placeholdersOnly bool
+)
+
+//设置全局的参数改写函数，传入nil取消
+//用法：log.SetRedactor(func(sql string, args []interface{}) []interface{} { ... })
+func SetRedactor(r Redactor) {
+	mu.Lock()
+	defer mu.Unlock()
+	redactor = r
+}
+
+//on为true时日志中不记录任何参数
+func SetPlaceholdersOnly(on bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	placeholdersOnly = on
+}
+
+//按全局设置处理将要写到日志中的参数，只记录占位符时返回nil
+func Redact(sql string, args []interface{}) []interface{} {
+	mu.RLock()
+	r, hidden := redactor, placeholdersOnly
+	mu.RUnlock()
+	if hidden {
+		return nil
+	}
+	if r != nil {
+		return r(sql, args)
+	}
+	return args
+}
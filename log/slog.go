@@ -0,0 +1,59 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// SlogLogger 把日志写到log/slog，SQL语句的记录作为结构化的属性输出，
+//配合slog.NewJSONHandler可以接入JSON日志系统
+type SlogLogger struct {
+	l *slog.Logger
+}
+
+//用法：engine.SetLogger(log.NewSlog(slog.New(slog.NewJSONHandler(os.Stderr, nil))))
+func NewSlog(l *slog.Logger) *SlogLogger {
+	return &SlogLogger{l: l}
+}
+
+var slogLevels = map[Level]slog.Level{DebugLevel: slog.LevelDebug, InfoLevel: slog.LevelInfo, WarnLevel: slog.LevelWarn, ErrorLevel: slog.LevelError}
+
+func (l *SlogLogger) log(ctx context.Context, level Level, format string, v ...interface{}) {
+	if l.l.Enabled(ctx, slogLevels[level]) {
+		l.l.Log(ctx, slogLevels[level], fmt.Sprintf(format, v...))
+	}
+}
+
+func (l *SlogLogger) Debugf(ctx context.Context, format string, v ...interface{}) {
+	l.log(ctx, DebugLevel, format, v...)
+}
+
+func (l *SlogLogger) Infof(ctx context.Context, format string, v ...interface{}) {
+	l.log(ctx, InfoLevel, format, v...)
+}
+
+func (l *SlogLogger) Warnf(ctx context.Context, format string, v ...interface{}) {
+	l.log(ctx, WarnLevel, format, v...)
+}
+
+func (l *SlogLogger) Errorf(ctx context.Context, format string, v ...interface{}) {
+	l.log(ctx, ErrorLevel, format, v...)
+}
+
+//消息为"sql"，属性为sql、args、rows、duration和error；成功的语句为Debug级别，失败的为Error级别
+func (l *SlogLogger) Trace(ctx context.Context, t *Trace) {
+	level := slog.LevelDebug
+	attrs := []slog.Attr{slog.String("sql", t.SQL), slog.Any("args", t.Args), slog.Int64("rows", t.Rows), slog.Duration("duration", t.Duration)}
+	if t.Err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, slog.String("error", t.Err.Error()))
+	}
+	l.l.LogAttrs(ctx, level, "sql", attrs...)
+}
+
+//Warn级别，消息为"slow query"，属性为sql、args、rows、duration、threshold和caller
+func (l *SlogLogger) SlowQuery(ctx context.Context, q *SlowQuery) {
+	l.l.LogAttrs(ctx, slog.LevelWarn, "slow query", slog.String("sql", q.SQL), slog.Any("args", q.Args), slog.Int64("rows", q.Rows),
+		slog.Duration("duration", q.Duration), slog.Duration("threshold", q.Threshold), slog.String("caller", q.Caller))
+}
@@ -0,0 +1,861 @@
+package myorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"myorm/dialect"
+	"myorm/schema"
+	"myorm/session"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+func difference(a []string, b []string) (diff []string) {
+	mapB := make(map[string]bool)
+	for _, v := range b {
+		mapB[v] = true
+	}
+	for _, v := range a {
+		if _, ok := mapB[v]; !ok {
+			diff = append(diff, v)
+		}
+	}
+	return
+}
+
+//a、b中都有的元素，按a中的顺序
+func intersection(a []string, b []string) []string {
+	return difference(a, difference(a, b))
+}
+
+//Migrate会删除列、索引或修改列的类型、而调用者没有允许时返回的错误，用errors.Is判断
+var ErrDestructiveMigration = errors.New("destructive migration not allowed")
+
+// MigrateOptions 迁移的选项
+type MigrateOptions struct {
+	//允许会丢失数据或可能因已有数据而失败的步骤：删除列、索引、外键、UNIQUE约束，修改列的类型或主键，
+	//给已有的列加上NOT NULL，添加没有默认值的NOT NULL列，以及SQLite重建表。
+	//默认不允许，Migrate遇到这些步骤时返回ErrDestructiveMigration，可以先用MigratePlan查看将要执行的语句和警告
+	AllowDestructive bool
+	//删除数据库中有、注解中没有声明的索引。默认只删除框架建立的（默认名字idx_表名_列名）索引，
+	//其他索引（如用SQL脚本建立的）保留
+	DropUnknownIndexes bool
+	//删除数据库中有、注解中没有声明的外键。默认只删除框架建立的（默认名字fk_表名_列名，或与声明的外键同名、同列）外键，
+	//其他外键保留，SQLite重建表时也会带上它们
+	DropUnknownForeignKeys bool
+}
+
+// MigrationPlan Migrate将要执行的语句
+type MigrationPlan struct {
+	Statements []string //按执行顺序
+	Warnings   []string //会丢失数据或可能失败的步骤，见MigrateOptions.AllowDestructive
+}
+
+//计划中是否有会丢失数据的步骤
+func (p *MigrationPlan) Destructive() bool {
+	return len(p.Warnings) > 0
+}
+
+//警告写成SQL注释放在语句之前，便于在代码评审中查看
+func (p *MigrationPlan) String() string {
+	var b strings.Builder
+	for _, w := range p.Warnings {
+		b.WriteString("-- WARNING: " + w + "\n")
+	}
+	for _, stmt := range p.Statements {
+		b.WriteString(stmt + "\n")
+	}
+	return b.String()
+}
+
+// Migrate table
+//models是新的结构体，本函数须根据它们更新表框架和数据表。
+//多个结构体按外键的依赖关系排序，被引用的表先建立；有循环引用时，循环中一个表的外键推迟到所有表建立之后再添加。
+//新增的字段用ALTER TABLE ADD COLUMN加到表中；删除字段时，支持重建表的数据库（SQLite）
+//按官方的流程重建表，保留主键、NOT NULL、默认值、索引和触发器，其他数据库使用ALTER TABLE DROP COLUMN。
+//索引和外键以注解中的声明为准：缺少的和定义不同的重新建立；没有声明的索引只有名字符合默认命名（idx_表名_列名）时被删除，
+//外键类似，见MigrateOptions.DropUnknownIndexes、DropUnknownForeignKeys。
+//全部结构体的迁移在一个事务中完成，任何一步失败都会回滚；设置了重试策略（SetRetryPolicy）时，
+//因暂时性错误失败的迁移会被重新执行。会丢失数据或可能失败的步骤（见MigrateOptions.AllowDestructive）
+//须使用MigrateWithOptions允许。
+func (engine *Engine) Migrate(models ...interface{}) error {
+	return engine.MigrateWithOptions(nil, models...)
+}
+
+//与Migrate()相同，但按照opts迁移，opts为nil时使用默认选项
+func (engine *Engine) MigrateWithOptions(opts *MigrateOptions, models ...interface{}) error {
+	if opts == nil {
+		opts = &MigrateOptions{}
+	}
+	ctx := context.Background()
+	//关闭外键约束只对当前连接有效，迁移的所有语句须在同一个连接上执行
+	conn, err := engine.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	s := engine.Session().WithConn(conn)
+	rebuilder, _ := engine.dialectSQL.(dialect.TableRebuilder)
+	fkOn := false
+	if rebuilder != nil {
+		if fkOn, err = rebuilder.ForeignKeysEnabled(ctx, s.DB()); err != nil {
+			return err
+		}
+	}
+	if fkOn {
+		//PRAGMA foreign_keys 在事务中无效，须在事务开始前关闭、提交之后恢复
+		if _, err = s.Raw(rebuilder.SetForeignKeysSQL(false)).Exec(); err != nil {
+			return err
+		}
+		defer func() { _, _ = s.Raw(rebuilder.SetForeignKeysSQL(true)).Exec() }()
+	}
+	//迁移事务与Transaction()一样按引擎的重试策略重试，每次按数据库当前的表结构重新比较
+	return engine.retry(s, func() error {
+		m := &migrator{s: s, opts: opts, rebuilder: rebuilder, fkOn: fkOn}
+		_, _, err := s.Transaction(func(s *session.Session) (*session.Session, interface{}, error) {
+			return s, nil, m.migrate(models)
+		})
+		return err
+	})
+}
+
+//比较结构体和数据库中的表，返回Migrate将要执行的语句和其中会丢失数据的步骤，不执行任何语句。
+//SQLite开启了外键约束时，Migrate还会在事务前后关闭、恢复外键约束，
+//并在提交前检查被重建的表，这些不在计划中。
+func (engine *Engine) MigratePlan(models ...interface{}) (*MigrationPlan, error) {
+	return engine.MigratePlanWithOptions(nil, models...)
+}
+
+//与MigratePlan()相同，但按照opts生成计划，opts为nil时使用默认选项
+func (engine *Engine) MigratePlanWithOptions(opts *MigrateOptions, models ...interface{}) (*MigrationPlan, error) {
+	m := &migrator{s: engine.Session(), opts: opts}
+	m.rebuilder, _ = engine.dialectSQL.(dialect.TableRebuilder)
+	stmts, err := m.planAll(models)
+	if err != nil {
+		return nil, err
+	}
+	return &MigrationPlan{Statements: stmts, Warnings: m.warnings}, nil
+}
+
+//一次迁移的状态，所有语句都在m.s的事务中执行
+type migrator struct {
+	s         *session.Session
+	opts      *MigrateOptions
+	rebuilder dialect.TableRebuilder //数据库需要重建表才能修改表结构时不为nil
+	fkOn      bool                   //迁移前连接开启了外键约束
+	rebuilt   []string               //被重建的表，提交前须检查外键约束
+	warnings  []string               //会丢失数据或可能失败的步骤
+	deferred  map[string]bool        //循环引用中推迟到所有表建立之后再添加的外键，键为"表名.外键名"
+	post      []string               //所有表迁移之后执行的语句
+	offline   bool                   //不连接数据库，把所有表都当作不存在（生成DDL）
+}
+
+//迁移的选项，没有设置时使用默认选项
+func (m *migrator) options() *MigrateOptions {
+	if m.opts == nil {
+		return &MigrateOptions{}
+	}
+	return m.opts
+}
+
+func (m *migrator) migrate(models []interface{}) error {
+	stmts, err := m.planAll(models)
+	if err != nil {
+		return err
+	}
+	if len(m.warnings) > 0 && !m.options().AllowDestructive {
+		return fmt.Errorf("%w: %s", ErrDestructiveMigration, strings.Join(m.warnings, "; "))
+	}
+	for _, stmt := range stmts {
+		if _, err := m.s.Raw(stmt).Exec(); err != nil {
+			return err
+		}
+	}
+	if !m.fkOn {
+		return nil
+	}
+	for _, table := range m.rebuilt {
+		violations, err := m.rebuilder.ForeignKeyCheck(m.s.Context(), m.s.DB(), table)
+		if err != nil {
+			return err
+		}
+		if len(violations) > 0 {
+			return fmt.Errorf("foreign key check failed after rebuilding %s: %s", table, strings.Join(violations, "; "))
+		}
+	}
+	return nil
+}
+
+//按依赖关系排列结构体，依次生成每个表的语句，最后是推迟添加的外键
+func (m *migrator) planAll(models []interface{}) ([]string, error) {
+	var tables []*schema.Schema
+	for _, model := range models {
+		tables = append(tables, schema.Parse(model, m.s.Dialect()))
+	}
+	order, deferred := m.sortTables(tables)
+	//SQLite建表时不检查被引用的表是否存在，也不能用ALTER TABLE添加外键，循环引用的外键直接写在建表语句中
+	if m.rebuilder == nil {
+		m.deferred = deferred
+	}
+	var stmts []string
+	for _, i := range order {
+		tableStmts, err := m.plan(models[i])
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, tableStmts...)
+	}
+	return append(stmts, m.post...), nil
+}
+
+//按外键的依赖关系排列表（返回在tables中的下标），被引用的表在前，没有依赖关系的表保持传入的顺序。
+//有循环引用时，从剩下的第一个表出发沿着外键找到循环中的一个表，它引用剩下的表的外键推迟添加。
+//同名的表只保留第一个。
+func (m *migrator) sortTables(tables []*schema.Schema) (order []int, deferred map[string]bool) {
+	deferred = make(map[string]bool)
+	index := make(map[string]int)
+	var remaining []int
+	for i, table := range tables {
+		if _, ok := index[table.Name]; !ok {
+			index[table.Name] = i
+			remaining = append(remaining, i)
+		}
+	}
+	done := make(map[string]bool)
+	//表引用的第一个还没有排好的其他表，没有时返回-1
+	dependency := func(table *schema.Schema) int {
+		for _, fk := range table.ForeignKeys {
+			if i, ok := index[fk.RefTable]; ok && fk.RefTable != table.Name && !done[fk.RefTable] && !deferred[table.Name+"."+fk.Name] {
+				return i
+			}
+		}
+		return -1
+	}
+	for len(remaining) > 0 {
+		next := 0
+		for next < len(remaining) && dependency(tables[remaining[next]]) >= 0 {
+			next++
+		}
+		if next == len(remaining) {
+			seen := make(map[int]bool)
+			cur := remaining[0]
+			for !seen[cur] {
+				seen[cur] = true
+				cur = dependency(tables[cur])
+			}
+			table := tables[cur]
+			for dep := dependency(table); dep >= 0; dep = dependency(table) {
+				for _, fk := range table.ForeignKeys {
+					if fk.RefTable == tables[dep].Name {
+						m.s.Logger().Infof(m.s.Context(), "circular foreign key %s on %s is deferred", fk.Name, table.Name)
+						deferred[table.Name+"."+fk.Name] = true
+					}
+				}
+			}
+			continue
+		}
+		order = append(order, remaining[next])
+		done[tables[remaining[next]].Name] = true
+		remaining = append(remaining[:next], remaining[next+1:]...)
+	}
+	return
+}
+
+//比较结构体和数据库中的表，生成需要执行的语句
+func (m *migrator) plan(value interface{}) ([]string, error) {
+	s := m.s
+	// s.Model(value)表示将根据value建立一个表框架并定为该会话的refTable（更新了refTable）
+	s.Model(value)
+	if m.offline || !s.HasTable() { // 如果本来就没有表，新建一个即可
+		if !m.offline {
+			m.s.Logger().Infof(m.s.Context(), "table %s doesn't exist", s.RefTable().Name)
+		}
+		var skip []*schema.ForeignKey
+		for _, fk := range s.RefTable().ForeignKeys {
+			if m.deferred[s.RefTable().Name+"."+fk.Name] {
+				skip = append(skip, fk)
+				m.post = append(m.post, m.addForeignKeySQL(fk))
+			}
+		}
+		indexes, err := s.CreateIndexesSQL()
+		return append([]string{s.CreateTableSQLWithout(s.RefTable().Name, skip)}, indexes...), err
+	}
+	table := s.RefTable()
+	info, err := s.Inspect(table.Name)
+	if err != nil {
+		return nil, err
+	}
+	info = declaredIndexes(table, info)
+	columns := columnNames(info)
+	addCols := difference(table.FieldNames, columns)
+	delCols := difference(columns, table.FieldNames)
+	renames := renamedColumns(table, addCols, delCols)
+	for old, name := range renames {
+		addCols, delCols = difference(addCols, []string{name}), difference(delCols, []string{old})
+	}
+	m.s.Logger().Infof(m.s.Context(), "added cols %v, deleted cols %v, renamed cols %v", addCols, delCols, renames)
+	for _, col := range delCols {
+		m.warnings = append(m.warnings, fmt.Sprintf("drop column %s.%s", table.Name, col))
+	}
+	for _, col := range addCols {
+		if f := table.GetField(col); f.IsNotNull() && f.DefaultValue() == nil && !f.AutoIncrement {
+			m.warnings = append(m.warnings, fmt.Sprintf("add NOT NULL column %s.%s without default", table.Name, col))
+		}
+	}
+	var changed []columnDiff
+	for _, col := range info.Columns {
+		name := col.Name
+		if renames[name] != "" {
+			name = renames[name]
+		}
+		if f := table.GetField(name); f != nil {
+			if d := diffColumn(f, col, info); d.changed() {
+				m.s.Logger().Infof(m.s.Context(), "changed col %s: %s", col.Name, d)
+				changed = append(changed, d)
+				m.warnings = append(m.warnings, d.warnings(table.Name, col)...)
+			}
+		}
+	}
+
+	//RENAME COLUMN会同时修改索引和外键中的列名，按改名后的列名比较
+	renamed := renameInfo(info, renames)
+	dropFKs, addFKs, keepFKs := m.diffForeignKeys(table, renamed, delCols)
+	canRename := len(renames) == 0 || s.Dialect().Capabilities().RenameColumn
+
+	//SQLite不能用ALTER TABLE增删外键，只能重建表
+	if m.rebuilder != nil && (len(delCols) > 0 || len(changed) > 0 || !m.canAddColumns(table, addCols) ||
+		len(dropFKs) > 0 || len(addFKs) > 0 || !canRename) {
+		//重建时按旧的建立语句重建索引，其中改名的列换成新列名（见rebuild），所以按改名后的列名比较
+		dropIndexes, createIndexes, err := m.diffIndexes(table, renamed)
+		if err != nil {
+			return nil, err
+		}
+		m.s.Logger().Infof(m.s.Context(), "dropped indexes %v, dropped foreign keys %v", dropIndexes, dropFKs)
+		stmts, err := m.rebuild(table, info, renames, delCols, append(dropIndexes, indexesUsing(info, delCols)...), keepFKs)
+		return append(stmts, createIndexes...), err
+	}
+	if !canRename {
+		return nil, fmt.Errorf("%w: rename column in %s", dialect.ErrNotSupported, table.Name)
+	}
+	dropIndexes, createIndexes, err := m.diffIndexes(table, renamed)
+	if err != nil {
+		return nil, err
+	}
+	m.s.Logger().Infof(m.s.Context(), "dropped indexes %v, dropped foreign keys %v", dropIndexes, dropFKs)
+	q := s.Dialect().Quote
+	alterer, _ := s.Dialect().(dialect.ColumnAlterer)
+	var stmts []string
+	for _, fk := range dropFKs {
+		if alterer == nil {
+			return nil, fmt.Errorf("%w: drop foreign key %s.%s", dialect.ErrNotSupported, table.Name, fk.Name)
+		}
+		stmts = append(stmts, alterer.DropForeignKeySQL(table.Name, fk.Name))
+	}
+	for _, index := range dropIndexes {
+		stmts = append(stmts, s.DropIndexSQL(index))
+	}
+	for _, col := range intersection(columns, keys(renames)) {
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s;", q(table.Name), q(col), q(renames[col])))
+	}
+	for _, col := range addCols {
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", q(table.Name), s.ColumnDefinition(table.GetField(col))))
+	}
+	for _, col := range delCols {
+		if !s.Dialect().Capabilities().DropColumn {
+			return nil, fmt.Errorf("%w: drop column %s.%s", dialect.ErrNotSupported, table.Name, col)
+		}
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", q(table.Name), q(col)))
+	}
+	for _, d := range changed {
+		if alterer == nil || d.primaryKey {
+			return nil, fmt.Errorf("%w: alter column %s.%s (%s)", dialect.ErrNotSupported, table.Name, d.field.Name, d)
+		}
+		if d.change != (dialect.ColumnChange{}) {
+			stmts = append(stmts, alterer.AlterColumnSQL(table.Name, columnDef(d.field), d.change)...)
+		}
+		if d.addUnique {
+			stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ADD UNIQUE (%s);", q(table.Name), q(d.field.Name)))
+		}
+		if d.dropUnique != "" {
+			stmts = append(stmts, alterer.DropUniqueSQL(table.Name, d.dropUnique))
+		}
+	}
+	for _, fk := range addFKs {
+		if m.deferred[table.Name+"."+fk.Name] {
+			m.post = append(m.post, m.addForeignKeySQL(fk))
+		} else {
+			stmts = append(stmts, m.addForeignKeySQL(fk))
+		}
+	}
+	return append(stmts, createIndexes...), nil
+}
+
+//在会话当前的表上添加外键
+func (m *migrator) addForeignKeySQL(fk *schema.ForeignKey) string {
+	s := m.s
+	return fmt.Sprintf("ALTER TABLE %s ADD %s;", s.Dialect().Quote(s.RefTable().Name), s.ForeignKeyDefinition(fk))
+}
+
+//注解中有renamedFrom的字段，在表中只有旧列、没有新列时需要改名，返回旧列名→新列名
+func renamedColumns(table *schema.Schema, addCols, delCols []string) map[string]string {
+	renames := make(map[string]string)
+	for _, col := range addCols {
+		if old := table.GetField(col).RenamedFrom; old != "" && len(intersection(delCols, []string{old})) > 0 {
+			renames[old] = col
+		}
+	}
+	return renames
+}
+
+func keys(m map[string]string) []string {
+	var result []string
+	for k := range m {
+		result = append(result, k)
+	}
+	return result
+}
+
+//把表结构中索引和外键的列名改成新的列名，不修改info
+func renameInfo(info *dialect.TableInfo, renames map[string]string) *dialect.TableInfo {
+	if len(renames) == 0 {
+		return info
+	}
+	rename := func(cols []string) []string {
+		result := make([]string, len(cols))
+		for i, col := range cols {
+			result[i] = col
+			if renames[col] != "" {
+				result[i] = renames[col]
+			}
+		}
+		return result
+	}
+	renamed := *info
+	renamed.Indexes = make([]dialect.IndexInfo, len(info.Indexes))
+	for i, idx := range info.Indexes {
+		idx.Columns = rename(idx.Columns)
+		renamed.Indexes[i] = idx
+	}
+	renamed.ForeignKeys = make([]dialect.ForeignKeyInfo, len(info.ForeignKeys))
+	for i, fk := range info.ForeignKeys {
+		fk.Columns = rename(fk.Columns)
+		renamed.ForeignKeys[i] = fk
+	}
+	return &renamed
+}
+
+//比较注解中声明的外键和数据库中的外键，返回需要删除的外键、需要添加的外键和保留的没有声明的外键。
+//SQLite不保存外键名，因此按定义（列、引用的表和列、ON DELETE/ON UPDATE）而不是名字比较。
+//没有声明的外键只有框架建立的、用到被删除的列的或选项允许时才删除，删除的外键都记入警告
+func (m *migrator) diffForeignKeys(table *schema.Schema, info *dialect.TableInfo, delCols []string) (drop []dialect.ForeignKeyInfo, add []*schema.ForeignKey, keep []dialect.ForeignKeyInfo) {
+	matched := make([]bool, len(info.ForeignKeys))
+	for _, fk := range table.ForeignKeys {
+		found := false
+		for i, old := range info.ForeignKeys {
+			if !matched[i] && sameForeignKey(fk, old) {
+				matched[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			add = append(add, fk)
+		}
+	}
+	//框架建立的外键：默认名字，或者与声明的外键同名、同列（定义被修改了）
+	owned := func(old dialect.ForeignKeyInfo) bool {
+		if strings.HasPrefix(old.Name, "fk_"+table.Name+"_") {
+			return true
+		}
+		for _, fk := range table.ForeignKeys {
+			if (old.Name != "" && old.Name == fk.Name) || strings.Join(fk.Columns, ",") == strings.Join(old.Columns, ",") {
+				return true
+			}
+		}
+		return false
+	}
+	for i, old := range info.ForeignKeys {
+		if matched[i] {
+			continue
+		}
+		if m.options().DropUnknownForeignKeys || owned(old) || len(intersection(old.Columns, delCols)) > 0 {
+			drop = append(drop, old)
+			m.warnings = append(m.warnings, fmt.Sprintf("drop foreign key %s on %s", describeForeignKey(old), table.Name))
+		} else {
+			keep = append(keep, old)
+		}
+	}
+	return
+}
+
+//SQLite的外键没有名字，用定义描述
+func describeForeignKey(fk dialect.ForeignKeyInfo) string {
+	if fk.Name != "" {
+		return fk.Name
+	}
+	return fmt.Sprintf("(%s) REFERENCES %s", strings.Join(fk.Columns, ", "), fk.RefTable)
+}
+
+//数据库中的外键在建表语句中的定义，重建表时保留没有声明的外键
+func (m *migrator) foreignKeyDefinition(fk dialect.ForeignKeyInfo) string {
+	q := m.s.Dialect().Quote
+	quoteAll := func(names []string) []string {
+		var result []string
+		for _, name := range names {
+			result = append(result, q(name))
+		}
+		return result
+	}
+	var def string
+	if fk.Name != "" {
+		def = "CONSTRAINT " + q(fk.Name) + " "
+	}
+	def += fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s", strings.Join(quoteAll(fk.Columns), ", "), q(fk.RefTable))
+	//SQLite引用主键时可以省略被引用的列
+	if len(intersection(fk.RefColumns, []string{""})) == 0 {
+		def += fmt.Sprintf(" (%s)", strings.Join(quoteAll(fk.RefColumns), ", "))
+	}
+	if action := fkAction(fk.OnDelete); action != "NO ACTION" {
+		def += " ON DELETE " + action
+	}
+	if action := fkAction(fk.OnUpdate); action != "NO ACTION" {
+		def += " ON UPDATE " + action
+	}
+	return def
+}
+
+func sameForeignKey(fk *schema.ForeignKey, old dialect.ForeignKeyInfo) bool {
+	return fk.RefTable == old.RefTable &&
+		strings.Join(fk.Columns, ",") == strings.Join(old.Columns, ",") &&
+		strings.Join(fk.RefColumns, ",") == strings.Join(old.RefColumns, ",") &&
+		fkAction(fk.OnDelete) == fkAction(old.OnDelete) && fkAction(fk.OnUpdate) == fkAction(old.OnUpdate)
+}
+
+//没有指定动作时数据库使用NO ACTION
+func fkAction(action string) string {
+	if action == "" {
+		return "NO ACTION"
+	}
+	return strings.ToUpper(action)
+}
+
+//比较注解中声明的索引和数据库中的索引（不含主键、UNIQUE约束和外键自动建立的索引），
+//返回需要删除的索引名和需要建立的索引的语句。列、是否唯一或是否部分索引不同的索引先删除再重建，
+//部分索引的条件由数据库改写过，无法比较，修改条件时须同时修改索引名。
+func (m *migrator) diffIndexes(table *schema.Schema, info *dialect.TableInfo) (drop []string, create []string, err error) {
+	existing := make(map[string]dialect.IndexInfo)
+	for _, idx := range info.Indexes {
+		if !idx.Primary && !idx.Constraint {
+			existing[idx.Name] = idx
+		}
+	}
+	//MySQL会为外键自动建立同名的索引，它随外键一起增删
+	for _, fk := range info.ForeignKeys {
+		delete(existing, fk.Name)
+	}
+	for _, idx := range table.Indexes {
+		old, ok := existing[idx.Name]
+		delete(existing, idx.Name)
+		if ok && old.Unique == idx.Unique && old.Partial == (idx.Where != "") && strings.Join(old.Columns, ",") == strings.Join(idx.Columns, ",") {
+			continue
+		}
+		if ok {
+			drop = append(drop, idx.Name)
+		}
+		stmt, err := m.s.CreateIndexSQL(idx)
+		if err != nil {
+			return nil, nil, err
+		}
+		create = append(create, stmt)
+	}
+	for _, idx := range info.Indexes {
+		if _, ok := existing[idx.Name]; ok && (m.options().DropUnknownIndexes || strings.HasPrefix(idx.Name, "idx_"+table.Name+"_")) {
+			drop = append(drop, idx.Name)
+		}
+	}
+	for _, name := range drop {
+		m.warnings = append(m.warnings, fmt.Sprintf("drop index %s on %s", name, table.Name))
+	}
+	return drop, create, nil
+}
+
+//MySQL的唯一索引同时也是UNIQUE约束，注解中声明了同名索引的按索引比较，不当作列上的UNIQUE约束。不修改info
+func declaredIndexes(table *schema.Schema, info *dialect.TableInfo) *dialect.TableInfo {
+	declared := make(map[string]bool)
+	for _, idx := range table.Indexes {
+		declared[idx.Name] = true
+	}
+	result := *info
+	result.Indexes = make([]dialect.IndexInfo, len(info.Indexes))
+	for i, idx := range info.Indexes {
+		if declared[idx.Name] {
+			idx.Constraint = false
+		}
+		result.Indexes[i] = idx
+	}
+	return &result
+}
+
+//一列在数据库中的定义与结构体中的定义的差异
+type columnDiff struct {
+	field      *schema.Field
+	change     dialect.ColumnChange
+	primaryKey bool   //是否为主键不同
+	addUnique  bool   //需要加上UNIQUE约束
+	dropUnique string //需要删除的UNIQUE约束对应的索引
+}
+
+func (d columnDiff) changed() bool {
+	return d.change != (dialect.ColumnChange{}) || d.primaryKey || d.addUnique || d.dropUnique != ""
+}
+
+func (d columnDiff) String() string {
+	var parts []string
+	for name, ok := range map[string]bool{"type": d.change.Type, "not null": d.change.NotNull, "default": d.change.Default,
+		"primary key": d.primaryKey, "unique": d.addUnique || d.dropUnique != ""} {
+		if ok {
+			parts = append(parts, name)
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+//会丢失数据或可能因已有数据而失败的修改
+func (d columnDiff) warnings(table string, col dialect.ColumnInfo) []string {
+	var warnings []string
+	if d.change.Type {
+		warnings = append(warnings, fmt.Sprintf("change type of column %s.%s from %s to %s", table, col.Name, col.Type, d.field.Type))
+	}
+	if d.change.NotNull && d.field.IsNotNull() {
+		warnings = append(warnings, fmt.Sprintf("set NOT NULL on column %s.%s", table, col.Name))
+	}
+	if d.primaryKey {
+		warnings = append(warnings, fmt.Sprintf("change primary key column %s.%s", table, col.Name))
+	}
+	if d.dropUnique != "" {
+		warnings = append(warnings, fmt.Sprintf("drop unique constraint on %s.%s", table, col.Name))
+	}
+	return warnings
+}
+
+func diffColumn(f *schema.Field, col dialect.ColumnInfo, info *dialect.TableInfo) columnDiff {
+	d := columnDiff{field: f}
+	//自增列的类型和默认值由数据库决定（如PostgreSQL的serial是带nextval默认值的integer）
+	if !f.AutoIncrement {
+		d.change.Type = normalizeType(col.Type) != normalizeType(f.Type)
+		d.change.Default = normalizeDefault(col.Default) != normalizeDefault(f.DefaultValue())
+	}
+	//主键列总是不能为空的，有的数据库却不会标记为NOT NULL
+	if !col.PrimaryKey && !f.IsPrimaryKey() {
+		d.change.NotNull = col.NotNull != f.IsNotNull()
+	}
+	d.primaryKey = col.PrimaryKey != f.IsPrimaryKey()
+	unique := ""
+	for _, idx := range info.Indexes {
+		if idx.Constraint && !idx.Primary && len(idx.Columns) == 1 && idx.Columns[0] == col.Name {
+			unique = idx.Name
+		}
+	}
+	d.addUnique = unique == "" && f.IsUnique()
+	if unique != "" && !f.IsUnique() {
+		d.dropUnique = unique
+	}
+	return d
+}
+
+func columnDef(f *schema.Field) dialect.ColumnDef {
+	return dialect.ColumnDef{Name: f.Name, Type: f.Type, NotNull: f.IsNotNull(), Default: f.DefaultValue(), AutoIncrement: f.AutoIncrement}
+}
+
+//同一个类型在不同的地方可能有不同的写法
+var typeAliases = map[string]string{
+	"timestamp with time zone": "timestamptz",
+	"bool":                     "boolean",
+	"int4":                     "integer",
+	"int8":                     "bigint",
+	"float8":                   "double precision",
+	"":                         "blob", //SQLite中没有声明类型的列与blob列一样没有类型亲和性
+}
+
+//MySQL 8.0.17之前的版本给整数类型加上显示宽度，如int(11)、bigint(20) unsigned。
+//宽度不影响取值范围，比较时去掉；tinyint(1)是布尔类型的写法，保留
+var displayWidthRegexp = regexp.MustCompile(`^(tinyint|smallint|mediumint|int|integer|bigint)\(\d+\)`)
+
+//把类型改写成统一的写法：小写、去掉整数的显示宽度，unsigned单独比较后放在最后
+func normalizeType(typ string) string {
+	var words []string
+	unsigned := false
+	for _, word := range strings.Fields(strings.ToLower(typ)) {
+		if word == "unsigned" {
+			unsigned = true
+		} else {
+			words = append(words, word)
+		}
+	}
+	typ = strings.Join(words, " ")
+	if typ != "tinyint(1)" {
+		typ = displayWidthRegexp.ReplaceAllString(typ, "$1")
+	}
+	if alias, ok := typeAliases[typ]; ok {
+		typ = alias
+	}
+	if unsigned {
+		typ += " unsigned"
+	}
+	return typ
+}
+
+//数据库返回的默认值与注解中的写法可能不同：PostgreSQL会加上类型转换（'a'::text），
+//MySQL返回的字符串默认值不带引号
+func normalizeDefault(dflt *string) string {
+	if dflt == nil {
+		return ""
+	}
+	v := strings.TrimSpace(*dflt)
+	if i := strings.LastIndex(v, "::"); i > 0 && !strings.Contains(v[i:], "'") {
+		v = v[:i]
+	}
+	if len(v) >= 2 && v[0] == '\'' && v[len(v)-1] == '\'' {
+		v = strings.ReplaceAll(v[1:len(v)-1], "''", "'")
+	}
+	return strings.ToLower(v)
+}
+
+//SQLite的ADD COLUMN不能添加主键、UNIQUE列，NOT NULL的列必须有默认值，这些情况只能重建表
+func (m *migrator) canAddColumns(table *schema.Schema, cols []string) bool {
+	for _, col := range cols {
+		tag := strings.ToUpper(table.GetField(col).Tag)
+		if strings.Contains(tag, "PRIMARY KEY") || strings.Contains(tag, "UNIQUE") ||
+			(strings.Contains(tag, "NOT NULL") && !strings.Contains(tag, "DEFAULT")) {
+			return false
+		}
+	}
+	return true
+}
+
+//重建表（https://www.sqlite.org/lang_altertable.html#otheralter）：
+//按结构体的完整定义和keepFKs中没有声明的外键新建表new_X，复制两边都有的列（改名的列从旧列复制），删除X，把new_X改名为X，
+//再重新建立X上的索引和触发器（跳过dropIndexes中的索引），以及引用X的视图。
+func (m *migrator) rebuild(table *schema.Schema, info *dialect.TableInfo, renames map[string]string, delCols []string, dropIndexes []string, keepFKs []dialect.ForeignKeyInfo) ([]string, error) {
+	s := m.s
+	q := s.Dialect().Quote
+	//新表的约束（如NOT NULL、UNIQUE、外键）可能与已有数据冲突，复制数据时失败
+	m.warnings = append(m.warnings, fmt.Sprintf("rebuild table %s", table.Name))
+	objects, err := m.rebuilder.TableObjects(s.Context(), s.DB(), table.Name)
+	if err != nil {
+		return nil, err
+	}
+	var stmts []string
+	for _, obj := range objects {
+		if obj.Type == "view" {
+			stmts = append(stmts, fmt.Sprintf("DROP VIEW %s;", q(obj.Name)))
+		}
+	}
+	tmp := "new_" + table.Name
+	var to, from []string
+	for _, name := range columnNames(info) {
+		if renames[name] != "" {
+			to, from = append(to, q(renames[name])), append(from, q(name))
+		} else if table.GetField(name) != nil {
+			to, from = append(to, q(name)), append(from, q(name))
+		}
+	}
+	var constraints []string
+	for _, fk := range keepFKs {
+		constraints = append(constraints, m.foreignKeyDefinition(fk))
+	}
+	stmts = append(stmts,
+		s.CreateTableSQLWithout(tmp, nil, constraints...),
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s;", q(tmp), strings.Join(to, ", "), strings.Join(from, ", "), q(table.Name)),
+		fmt.Sprintf("DROP TABLE %s;", q(table.Name)),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s;", q(tmp), q(table.Name)),
+	)
+	var oldCols []string
+	for old := range renames {
+		oldCols = append(oldCols, old)
+	}
+	sort.Strings(oldCols)
+	for _, obj := range objects {
+		if obj.Type == "index" {
+			if len(intersection([]string{obj.Name}, dropIndexes)) == 0 {
+				stmts = append(stmts, renameIndexColumns(obj.SQL, renames, q)+";")
+			}
+			continue
+		}
+		//触发器和视图中的列名无法可靠地改写（可能是其他表的同名列），用到删除或改名的列时不再建立
+		if col := referencedColumn(obj.SQL, append(oldCols, delCols...)); col != "" {
+			m.warnings = append(m.warnings, fmt.Sprintf("drop %s %s: it uses column %s.%s", obj.Type, obj.Name, table.Name, col))
+			continue
+		}
+		stmts = append(stmts, obj.SQL+";")
+	}
+	m.rebuilt = append(m.rebuilt, table.Name)
+	return stmts, nil
+}
+
+//SQL中的标识符（可能加了引号）和字符串
+var tokenRegexp = regexp.MustCompile("\"(?:[^\"]|\"\")*\"|`(?:[^`]|``)*`|\\[[^\\]]*\\]|'(?:[^']|'')*'|[\\w$]+")
+
+//去掉标识符的引号，字符串返回空
+func unquoteIdentifier(token string) string {
+	switch token[0] {
+	case '"', '`':
+		return strings.ReplaceAll(token[1:len(token)-1], token[:1]+token[:1], token[:1])
+	case '[':
+		return token[1 : len(token)-1]
+	case '\'':
+		return ""
+	}
+	return token
+}
+
+//把索引建立语句中列表和WHERE条件（第一个括号之后）的旧列名改成新列名，列名不区分大小写
+func renameIndexColumns(stmt string, renames map[string]string, quote func(string) string) string {
+	i := strings.Index(stmt, "(")
+	if i < 0 || len(renames) == 0 {
+		return stmt
+	}
+	lower := make(map[string]string, len(renames))
+	for old, name := range renames {
+		lower[strings.ToLower(old)] = name
+	}
+	return stmt[:i] + tokenRegexp.ReplaceAllStringFunc(stmt[i:], func(token string) string {
+		if name, ok := lower[strings.ToLower(unquoteIdentifier(token))]; ok {
+			return quote(name)
+		}
+		return token
+	})
+}
+
+//sql中用到的第一个cols中的列，没有时返回空
+func referencedColumn(sql string, cols []string) string {
+	for _, token := range tokenRegexp.FindAllString(sql, -1) {
+		for _, col := range cols {
+			if strings.EqualFold(unquoteIdentifier(token), col) {
+				return col
+			}
+		}
+	}
+	return ""
+}
+
+func columnNames(info *dialect.TableInfo) []string {
+	var names []string
+	for _, col := range info.Columns {
+		names = append(names, col.Name)
+	}
+	return names
+}
+
+//用到了cols中的列的索引，这些索引随列一起删除
+func indexesUsing(info *dialect.TableInfo, cols []string) []string {
+	var names []string
+	for _, idx := range info.Indexes {
+		if len(intersection(idx.Columns, cols)) > 0 {
+			names = append(names, idx.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,599 @@
+package myorm
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"myorm/dialect"
+	"myorm/internal/fakedb"
+	"myorm/session"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type Product struct {
+	ID    int    `myorm:"PRIMARY KEY"`
+	Name  string `myorm:"NOT NULL DEFAULT 'none'"`
+	Price int
+	Stock int
+}
+
+func createProducts(t *testing.T, engine *Engine) {
+	s := engine.NewSession().Model(&Product{})
+	for _, stmt := range []string{
+		s.CreateTableSQL("Product"),
+		"CREATE INDEX idx_product_price ON Product (Price)",
+		"CREATE INDEX idx_product_stock ON Product (Stock)",
+		"CREATE TABLE Review (ID integer PRIMARY KEY, ProductID integer REFERENCES Product(ID) ON DELETE CASCADE)",
+		"INSERT INTO Product (ID, Name, Price, Stock) VALUES (1, 'pen', 3, 10)",
+		"INSERT INTO Review (ID, ProductID) VALUES (1, 1)",
+	} {
+		if _, err := s.Raw(stmt).Exec(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestEngine_MigrateRebuild(t *testing.T) {
+	engine, err := NewEngine("sqlite3", filepath.Join(t.TempDir(), "myorm.db")+"?_foreign_keys=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+	createProducts(t, engine)
+
+	//去掉了Stock字段
+	type Product struct {
+		ID    int    `myorm:"PRIMARY KEY"`
+		Name  string `myorm:"NOT NULL DEFAULT 'none'"`
+		Price int    `myorm:"index:idx_product_price"`
+	}
+	plan, err := engine.MigratePlan(&Product{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(plan.Warnings, []string{"drop column Product.Stock", "rebuild table Product"}) ||
+		plan.Statements[0] != `CREATE TABLE "new_Product" ("ID" integer PRIMARY KEY,"Name" text NOT NULL DEFAULT 'none',"Price" integer );` {
+		t.Fatalf("unexpected plan:\n%s", plan)
+	}
+	if err := engine.Migrate(&Product{}); !errors.Is(err, ErrDestructiveMigration) {
+		t.Fatal("dropping a column should require AllowDestructive", err)
+	}
+	if err := engine.MigrateWithOptions(&MigrateOptions{AllowDestructive: true}, &Product{}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := engine.Inspect("Product")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Columns) != 3 || !info.Columns[0].PrimaryKey || !info.Columns[1].NotNull || *info.Columns[1].Default != "'none'" {
+		t.Fatal("rebuild lost column constraints", info.Columns)
+	}
+	if len(info.Indexes) != 1 || info.Indexes[0].Name != "idx_product_price" {
+		t.Fatal("rebuild should keep idx_product_price and drop idx_product_stock", info.Indexes)
+	}
+	var name string
+	var price, reviews int
+	s := engine.NewSession()
+	_ = s.Raw("SELECT Name, Price FROM Product WHERE ID = 1").QueryRow().Scan(&name, &price)
+	_ = s.Raw("SELECT count(*) FROM Review").QueryRow().Scan(&reviews)
+	if name != "pen" || price != 3 {
+		t.Fatal("rebuild lost data", name, price)
+	}
+	//删除旧表时不能触发Review上的ON DELETE CASCADE
+	if reviews != 1 {
+		t.Fatal("rebuild cascaded delete to Review")
+	}
+}
+
+//迁移计划中的语句，t失败时结束
+func planFor(t *testing.T, engine *Engine, value interface{}) []string {
+	t.Helper()
+	plan, err := engine.MigratePlan(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return plan.Statements
+}
+
+func TestEngine_MigrateAlterColumns(t *testing.T) {
+	engine := OpenDB(t)
+	createProducts(t, engine)
+
+	type Product struct {
+		ID    int    `myorm:"PRIMARY KEY"`
+		Name  string `myorm:"NOT NULL UNIQUE DEFAULT 'none'"`
+		Price string
+		Stock int `myorm:"NOT NULL DEFAULT 0"`
+	}
+	if err := engine.MigrateWithOptions(&MigrateOptions{AllowDestructive: true}, &Product{}); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := engine.Inspect("Product")
+	price, stock := info.Columns[2], info.Columns[3]
+	if price.Type != "text" || !stock.NotNull || *stock.Default != "0" {
+		t.Fatal("failed to alter columns", info.Columns)
+	}
+	unique := false
+	for _, idx := range info.Indexes {
+		unique = unique || (idx.Constraint && idx.Columns[0] == "Name")
+	}
+	if !unique {
+		t.Fatal("failed to add unique constraint", info.Indexes)
+	}
+	var p Product
+	if err := engine.NewSession().Model(&p).First(&p); err != nil || p.Price != "3" || p.Stock != 10 {
+		t.Fatal("failed to keep data", p, err)
+	}
+	if stmts := planFor(t, engine, &Product{}); len(stmts) != 0 {
+		t.Fatal("migrated table should not change again", stmts)
+	}
+}
+
+func TestNormalizeType(t *testing.T) {
+	for _, c := range [][2]string{
+		{"int(11)", "int"},
+		{"INT", "int"},
+		{"bigint(20) unsigned", "bigint unsigned"},
+		{"int(10) UNSIGNED", "int unsigned"},
+		{"tinyint(4)", "tinyint"},
+		{"tinyint(1)", "tinyint(1)"},
+		{"varchar(255)", "varchar(255)"},
+		{"timestamp  with time zone", "timestamptz"},
+		{"int8", "bigint"},
+	} {
+		if got := normalizeType(c[0]); got != c[1] {
+			t.Errorf("normalizeType(%q) = %q, want %q", c[0], got, c[1])
+		}
+	}
+	if normalizeType("int unsigned") == normalizeType("int(11)") {
+		t.Error("unsigned and signed types should differ")
+	}
+}
+
+func TestEngine_MigrateAlterPostgres(t *testing.T) {
+	dial, _ := dialect.GetDialect("postgres")
+	db, rec := fakedb.Open()
+	defer db.Close()
+	rec.Rows = func(query string, args []interface{}) ([]string, [][]interface{}) {
+		switch {
+		case strings.Contains(query, "pg_tables"):
+			return []string{"tablename"}, [][]interface{}{{"Product"}}
+		case strings.Contains(query, "pg_attribute a JOIN"):
+			return []string{"name", "type", "notnull", "default", "pk"}, [][]interface{}{
+				{"ID", "bigint", true, nil, true},
+				{"Name", "text", false, nil, false},
+				{"Price", "integer", false, nil, false},
+				{"Stock", "integer", false, "0", false},
+			}
+		case strings.Contains(query, "pg_index ix"):
+			return []string{"name", "unique", "primary", "partial", "constraint", "column"}, [][]interface{}{
+				{"Product_Stock_key", true, false, false, true, "Stock"},
+			}
+		}
+		return nil, nil
+	}
+	type Product struct {
+		ID    int64  `myorm:"PRIMARY KEY"`
+		Name  string `myorm:"NOT NULL UNIQUE DEFAULT 'none'"`
+		Price string
+		Stock int `myorm:"DEFAULT 0"`
+	}
+	s := session.New(db, dial)
+	_ = s.Begin()
+	m := &migrator{s: s}
+	stmts, err := m.plan(&Product{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	warnings := []string{
+		"set NOT NULL on column Product.Name",
+		"change type of column Product.Price from integer to text",
+		"drop unique constraint on Product.Stock",
+	}
+	if !reflect.DeepEqual(m.warnings, warnings) {
+		t.Fatal("unexpected warnings", m.warnings)
+	}
+	want := []string{
+		`ALTER TABLE "Product" ALTER COLUMN "Name" SET NOT NULL;`,
+		`ALTER TABLE "Product" ALTER COLUMN "Name" SET DEFAULT 'none';`,
+		`ALTER TABLE "Product" ADD UNIQUE ("Name");`,
+		`ALTER TABLE "Product" ALTER COLUMN "Price" TYPE text USING "Price"::text;`,
+		`ALTER TABLE "Product" DROP CONSTRAINT "Product_Stock_key";`,
+	}
+	if !reflect.DeepEqual(stmts, want) {
+		t.Fatalf("unexpected statements:\n%s", strings.Join(stmts, "\n"))
+	}
+}
+
+func TestEngine_MigrateIndexes(t *testing.T) {
+	engine := OpenDB(t)
+	createProducts(t, engine)
+
+	//保留idx_product_price；idx_product_stock没有声明，但不是框架建立的，也保留
+	type Product struct {
+		ID    int    `myorm:"PRIMARY KEY"`
+		Name  string `myorm:"NOT NULL DEFAULT 'none';uniqueIndex;index:idx_name_price"`
+		Price int    `myorm:"index:idx_product_price;index:idx_name_price,priority:1"`
+		Stock int    `myorm:"index:idx_in_stock,where:Stock > 0"`
+	}
+	if err := engine.Migrate(&Product{}); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := engine.Inspect("Product")
+	var got []string
+	for _, idx := range info.Indexes {
+		got = append(got, fmt.Sprint(idx.Name, idx.Columns, idx.Unique, idx.Partial))
+	}
+	want := []string{
+		"idx_Product_Name[Name] true false",
+		"idx_in_stock[Stock] false true",
+		"idx_name_price[Price Name] false false",
+		"idx_product_price[Price] false false",
+		"idx_product_stock[Stock] false false",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatal("unexpected indexes", got)
+	}
+	if stmts := planFor(t, engine, &Product{}); len(stmts) != 0 {
+		t.Fatal("migrated indexes should not change again", stmts)
+	}
+
+	//框架建立的idx_Product_Name不再声明时被删除；选项允许时也删除没有声明的idx_product_stock，都须要AllowDestructive
+	{
+		type Product struct {
+			ID    int    `myorm:"PRIMARY KEY"`
+			Name  string `myorm:"NOT NULL DEFAULT 'none';index:idx_name_price"`
+			Price int    `myorm:"index:idx_product_price;index:idx_name_price,priority:1"`
+			Stock int    `myorm:"index:idx_in_stock,where:Stock > 0"`
+		}
+		plan, err := engine.MigratePlan(&Product{})
+		if err != nil || !reflect.DeepEqual(plan.Warnings, []string{"drop index idx_Product_Name on Product"}) {
+			t.Fatalf("unexpected plan:\n%s%v", plan, err)
+		}
+		opts := &MigrateOptions{DropUnknownIndexes: true}
+		if err := engine.MigrateWithOptions(opts, &Product{}); !errors.Is(err, ErrDestructiveMigration) {
+			t.Fatal("dropping indexes should require AllowDestructive", err)
+		}
+		opts.AllowDestructive = true
+		if err := engine.MigrateWithOptions(opts, &Product{}); err != nil {
+			t.Fatal(err)
+		}
+		info, _ := engine.Inspect("Product")
+		if len(info.Indexes) != 3 {
+			t.Fatal("failed to drop indexes", info.Indexes)
+		}
+	}
+}
+
+func TestEngine_MigrateForeignKeys(t *testing.T) {
+	engine := OpenDB(t)
+	createProducts(t, engine)
+
+	//Review原来的外键没有ON UPDATE，新增的Tag表引用Review
+	type Review struct {
+		ID        int `myorm:"PRIMARY KEY"`
+		ProductID int `myorm:"references:Product(ID);constraint:OnDelete:CASCADE,OnUpdate:CASCADE"`
+	}
+	type Tag struct {
+		ReviewID int `myorm:"foreignKey:fk_tag_review;references:Review(ID);constraint:OnDelete:SET NULL"`
+		Name     string
+	}
+	//替换外键要删除旧的外键，须AllowDestructive
+	for _, model := range []interface{}{&Review{}, &Tag{}} {
+		if err := engine.MigrateWithOptions(&MigrateOptions{AllowDestructive: true}, model); err != nil {
+			t.Fatal(err)
+		}
+		if stmts := planFor(t, engine, model); len(stmts) != 0 {
+			t.Fatal("migrated foreign keys should not change again", stmts)
+		}
+	}
+	info, _ := engine.Inspect("Review")
+	if len(info.ForeignKeys) != 1 || info.ForeignKeys[0].OnUpdate != "CASCADE" || info.ForeignKeys[0].OnDelete != "CASCADE" {
+		t.Fatal("failed to replace foreign key of Review", info.ForeignKeys)
+	}
+
+	//引擎默认开启了外键约束
+	s := engine.NewSession()
+	if _, err := s.Raw("INSERT INTO Tag (ReviewID, Name) VALUES (1, 'good'), (2, 'bad')").Exec(); err == nil {
+		t.Fatal("insert should fail for missing review")
+	}
+	if _, err := s.Raw("INSERT INTO Tag (ReviewID, Name) VALUES (1, 'good')").Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Raw("UPDATE Product SET ID = 2").Exec(); err != nil {
+		t.Fatal(err)
+	}
+	var productID int
+	_ = s.Raw("SELECT ProductID FROM Review").QueryRow().Scan(&productID)
+	if _, err := s.Raw("DELETE FROM Product").Exec(); err != nil {
+		t.Fatal(err)
+	}
+	var reviews int
+	var reviewID sql.NullInt64
+	_ = s.Raw("SELECT count(*) FROM Review").QueryRow().Scan(&reviews)
+	_ = s.Raw("SELECT ReviewID FROM Tag").QueryRow().Scan(&reviewID)
+	if productID != 2 || reviews != 0 || reviewID.Valid {
+		t.Fatal("foreign key actions didn't work", productID, reviews, reviewID)
+	}
+}
+
+func TestEngine_MigrateUnknownForeignKeys(t *testing.T) {
+	engine := OpenDB(t)
+	createProducts(t, engine)
+
+	//Review上用SQL建立的外键没有声明，重建表（新增UNIQUE列）时保留。重建表须AllowDestructive
+	type Review struct {
+		ID        int `myorm:"PRIMARY KEY"`
+		ProductID int
+		Code      string `myorm:"UNIQUE"`
+	}
+	if err := engine.MigrateWithOptions(&MigrateOptions{AllowDestructive: true}, &Review{}); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := engine.Inspect("Review")
+	if len(info.Columns) != 3 || len(info.ForeignKeys) != 1 || info.ForeignKeys[0].OnDelete != "CASCADE" {
+		t.Fatal("rebuild should keep undeclared foreign key", info.Columns, info.ForeignKeys)
+	}
+	if stmts := planFor(t, engine, &Review{}); len(stmts) != 0 {
+		t.Fatal("migrated table should not change again", stmts)
+	}
+
+	opts := &MigrateOptions{DropUnknownForeignKeys: true}
+	plan, err := engine.MigratePlanWithOptions(opts, &Review{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Destructive() || plan.Warnings[0] != "drop foreign key (ProductID) REFERENCES Product on Review" {
+		t.Fatalf("unexpected plan:\n%s", plan)
+	}
+	opts.AllowDestructive = true
+	if err := engine.MigrateWithOptions(opts, &Review{}); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := engine.Inspect("Review"); len(info.ForeignKeys) != 0 {
+		t.Fatal("failed to drop undeclared foreign key", info.ForeignKeys)
+	}
+}
+
+func TestEngine_MigrateForeignKeysPostgres(t *testing.T) {
+	dial, _ := dialect.GetDialect("postgres")
+	db, rec := fakedb.Open()
+	defer db.Close()
+	rec.Rows = func(query string, args []interface{}) ([]string, [][]interface{}) {
+		switch {
+		case strings.Contains(query, "pg_tables"):
+			return []string{"tablename"}, [][]interface{}{{"Review"}}
+		case strings.Contains(query, "pg_attribute a JOIN"):
+			return []string{"name", "type", "notnull", "default", "pk"}, [][]interface{}{
+				{"ID", "bigint", true, nil, true},
+				{"ProductID", "bigint", false, nil, false},
+			}
+		case strings.Contains(query, "contype = 'f'"):
+			return []string{"name", "column", "reftable", "refcolumn", "onupdate", "ondelete"}, [][]interface{}{
+				{"Review_ProductID_fkey", "ProductID", "Product", "ID", "a", "a"},
+			}
+		}
+		return nil, nil
+	}
+	type Review struct {
+		ID        int64 `myorm:"PRIMARY KEY"`
+		ProductID int64 `myorm:"references:Product(ID);constraint:OnDelete:CASCADE"`
+	}
+	s := session.New(db, dial)
+	_ = s.Begin()
+	stmts, err := (&migrator{s: s}).plan(&Review{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`ALTER TABLE "Review" DROP CONSTRAINT "Review_ProductID_fkey";`,
+		`ALTER TABLE "Review" ADD CONSTRAINT "fk_Review_ProductID" FOREIGN KEY ("ProductID") REFERENCES "Product" ("ID") ON DELETE CASCADE;`,
+	}
+	if !reflect.DeepEqual(stmts, want) {
+		t.Fatalf("unexpected statements:\n%s", strings.Join(stmts, "\n"))
+	}
+}
+
+func TestEngine_MigrateRename(t *testing.T) {
+	engine := OpenDB(t)
+	createProducts(t, engine)
+
+	type Product struct {
+		ID       int    `myorm:"PRIMARY KEY"`
+		Name     string `myorm:"NOT NULL DEFAULT 'none'"`
+		Price    int    `myorm:"index:idx_product_price"`
+		Quantity int    `myorm:"renamedFrom:Stock;index:idx_product_stock"`
+	}
+	plan, err := engine.MigratePlan(&Product{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{`ALTER TABLE "Product" RENAME COLUMN "Stock" TO "Quantity";`}
+	if !reflect.DeepEqual(plan.Statements, want) || plan.Destructive() {
+		t.Fatalf("unexpected plan:\n%s", plan)
+	}
+	if err := engine.Migrate(&Product{}); err != nil {
+		t.Fatal(err)
+	}
+	if stmts := planFor(t, engine, &Product{}); len(stmts) != 0 {
+		t.Fatal("renamed column should not change again", stmts)
+	}
+	var p Product
+	if err := engine.NewSession().Model(&p).First(&p); err != nil || p.Quantity != 10 {
+		t.Fatal("failed to keep data of renamed column", p, err)
+	}
+}
+
+func TestEngine_MigrateRenameRebuild(t *testing.T) {
+	engine := OpenDB(t)
+	createProducts(t, engine)
+
+	//删除Name时须重建表，改名的列从旧列复制数据，用到旧列的索引按注解重新建立
+	type Product struct {
+		ID       int `myorm:"PRIMARY KEY"`
+		Price    int `myorm:"index:idx_product_price"`
+		Quantity int `myorm:"renamedFrom:Stock;index:idx_product_stock"`
+	}
+	if err := engine.MigrateWithOptions(&MigrateOptions{AllowDestructive: true}, &Product{}); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := engine.Inspect("Product")
+	if len(info.Columns) != 3 || info.Columns[2].Name != "Quantity" || len(info.Indexes) != 2 || info.Indexes[1].Columns[0] != "Quantity" {
+		t.Fatal("failed to rebuild with renamed column", info.Columns, info.Indexes)
+	}
+	var p Product
+	if err := engine.NewSession().Model(&p).First(&p); err != nil || p.Quantity != 10 || p.Price != 3 {
+		t.Fatal("failed to keep data of renamed column", p, err)
+	}
+}
+
+func TestEngine_MigrateRenameRebuildObjects(t *testing.T) {
+	engine := OpenDB(t)
+	{
+		type Person struct {
+			ID   int    `myorm:"PRIMARY KEY"`
+			Name string `myorm:"index:idx_name"`
+			Age  int
+			Junk string
+		}
+		if err := engine.Migrate(&Person{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, stmt := range []string{
+		`CREATE INDEX extra ON Person (Name, Age) WHERE Name <> 'Name'`,
+		`CREATE TRIGGER trg_name AFTER UPDATE OF Name ON Person BEGIN SELECT NEW.Name; END`,
+		`CREATE TRIGGER trg_age AFTER UPDATE OF Age ON Person BEGIN SELECT NEW.Age; END`,
+		`INSERT INTO Person (ID, Name, Age, Junk) VALUES (1, 'Tom', 18, 'x')`,
+	} {
+		if _, err := engine.NewSession().Raw(stmt).Exec(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//删除Junk时须重建表：没有声明的索引中的旧列名改成新列名，声明的索引不变，用到旧列名的触发器不再建立
+	type Person struct {
+		ID       int    `myorm:"PRIMARY KEY"`
+		FullName string `myorm:"renamedFrom:Name;index:idx_name"`
+		Age      int
+	}
+	plan, err := engine.MigratePlan(&Person{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"drop column Person.Junk", "rebuild table Person", "drop trigger trg_name: it uses column Person.Name"}
+	if !reflect.DeepEqual(plan.Warnings, want) {
+		t.Fatalf("unexpected warnings:\n%s", plan)
+	}
+	if err := engine.MigrateWithOptions(&MigrateOptions{AllowDestructive: true}, &Person{}); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := engine.Inspect("Person")
+	indexes := make(map[string][]string)
+	for _, idx := range info.Indexes {
+		indexes[idx.Name] = idx.Columns
+	}
+	if !reflect.DeepEqual(indexes, map[string][]string{"extra": {"FullName", "Age"}, "idx_name": {"FullName"}}) {
+		t.Fatal("failed to keep indexes on the renamed column", info.Indexes)
+	}
+	var triggers []string
+	rows, _ := engine.NewSession().Raw("SELECT name FROM sqlite_master WHERE type = 'trigger'").QueryRows()
+	for rows.Next() {
+		var name string
+		_ = rows.Scan(&name)
+		triggers = append(triggers, name)
+	}
+	_ = rows.Close()
+	if !reflect.DeepEqual(triggers, []string{"trg_age"}) {
+		t.Fatal("unexpected triggers", triggers)
+	}
+	var p Person
+	if err := engine.NewSession().Model(&p).First(&p); err != nil || p.FullName != "Tom" || p.Age != 18 {
+		t.Fatal("failed to keep data of renamed column", p, err)
+	}
+	if stmts := planFor(t, engine, &Person{}); len(stmts) != 0 {
+		t.Fatal("migrated table should not change again", stmts)
+	}
+}
+
+//互相引用的两个表
+type Author struct {
+	ID       int64 `myorm:"PRIMARY KEY"`
+	Favorite int64 `myorm:"references:Book(ID)"`
+}
+
+type Book struct {
+	ID       int64 `myorm:"PRIMARY KEY"`
+	AuthorID int64 `myorm:"references:Author(ID)"`
+}
+
+type Shelf struct {
+	ID     int64 `myorm:"PRIMARY KEY"`
+	BookID int64 `myorm:"references:Book(ID)"`
+}
+
+func TestEngine_MigrateModels(t *testing.T) {
+	engine := OpenDB(t)
+	createProducts(t, engine)
+
+	//被引用的表先建立，Book和Author循环引用，从Book断开；SQLite的外键都直接写在建表语句中
+	plan, err := engine.MigratePlan(&Shelf{}, &Book{}, &Author{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tables []string
+	for _, stmt := range plan.Statements {
+		if strings.HasPrefix(stmt, "CREATE TABLE") {
+			tables = append(tables, strings.Fields(stmt)[2])
+		}
+	}
+	if !reflect.DeepEqual(tables, []string{`"Book"`, `"Shelf"`, `"Author"`}) || len(plan.Statements) != 3 {
+		t.Fatalf("unexpected plan:\n%s", plan)
+	}
+	if err := engine.Migrate(&Shelf{}, &Book{}, &Author{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Author", "Book", "Shelf"} {
+		if info, _ := engine.Inspect(name); info == nil || len(info.ForeignKeys) != 1 {
+			t.Fatal("failed to create foreign key of", name)
+		}
+	}
+
+	//全部模型在一个事务中迁移，一个失败时都不生效
+	type Good struct {
+		ID int `myorm:"PRIMARY KEY"`
+	}
+	type Bad struct {
+		ID int `myorm:"PRIMARY KEY CHECK ("`
+	}
+	if err := engine.Migrate(&Good{}, &Bad{}); err == nil {
+		t.Fatal("migration of Bad should fail")
+	}
+	if tables, _ := engine.Tables(); len(tables) != 5 {
+		t.Fatal("failed migration should be rolled back", tables)
+	}
+}
+
+func TestEngine_MigrateModelsPostgres(t *testing.T) {
+	dial, _ := dialect.GetDialect("postgres")
+	db, _ := fakedb.Open()
+	defer db.Close()
+	engine := &Engine{db: db, dialectSQL: dial}
+	plan, err := engine.MigratePlan(&Shelf{}, &Book{}, &Author{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//Book引用的Author还没有建立，这个外键在最后添加
+	want := []string{
+		`CREATE TABLE "Book" ("ID" bigint PRIMARY KEY,"AuthorID" bigint );`,
+		`CREATE TABLE "Shelf" ("ID" bigint PRIMARY KEY,"BookID" bigint ,CONSTRAINT "fk_Shelf_BookID" FOREIGN KEY ("BookID") REFERENCES "Book" ("ID"));`,
+		`CREATE TABLE "Author" ("ID" bigint PRIMARY KEY,"Favorite" bigint ,CONSTRAINT "fk_Author_Favorite" FOREIGN KEY ("Favorite") REFERENCES "Book" ("ID"));`,
+		`ALTER TABLE "Book" ADD CONSTRAINT "fk_Book_AuthorID" FOREIGN KEY ("AuthorID") REFERENCES "Author" ("ID");`,
+	}
+	if !reflect.DeepEqual(plan.Statements, want) {
+		t.Fatalf("unexpected plan:\n%s", plan)
+	}
+}
@@ -0,0 +1,63 @@
+package migration
+
+import (
+	"fmt"
+	"myorm/session"
+	"reflect"
+	"time"
+)
+
+//历史表中的一条记录
+type record struct {
+	Version   int64
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+//历史表不存在时建立它。列的类型由方言决定，与建立普通的表一样
+func (m *Migrator) ensureTable(s *session.Session) error {
+	d := s.Dialect()
+	typeOf := func(v interface{}) string { return d.DataTypeOf(reflect.ValueOf(v)) }
+	_, err := s.Raw(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s %s PRIMARY KEY, %s %s NOT NULL, %s %s NOT NULL, %s %s NOT NULL)",
+		d.Quote(m.table), d.Quote("version"), typeOf(int64(0)), d.Quote("name"), typeOf(""),
+		d.Quote("checksum"), typeOf(""), d.Quote("applied_at"), typeOf(time.Time{}))).Exec()
+	return err
+}
+
+//已执行的迁移
+func (m *Migrator) applied() (map[int64]record, error) {
+	s := m.engine.Session()
+	if err := m.ensureTable(s); err != nil {
+		return nil, err
+	}
+	q := s.Dialect().Quote
+	rows, err := s.Raw(fmt.Sprintf("SELECT %s, %s, %s, %s FROM %s", q("version"), q("name"), q("checksum"), q("applied_at"), q(m.table))).QueryRows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make(map[int64]record)
+	for rows.Next() {
+		var rec record
+		if err := rows.Scan(&rec.Version, &rec.Name, &rec.Checksum, &rec.AppliedAt); err != nil {
+			return nil, err
+		}
+		result[rec.Version] = rec
+	}
+	return result, rows.Err()
+}
+
+//在迁移的事务中记录它已执行
+func (m *Migrator) record(s *session.Session, mg *Migration) error {
+	q := s.Dialect().Quote
+	_, err := s.Raw(fmt.Sprintf("INSERT INTO %s (%s, %s, %s, %s) VALUES (?, ?, ?, ?)", q(m.table), q("version"), q("name"), q("checksum"), q("applied_at")),
+		mg.Version, mg.Name, mg.Checksum, time.Now().UTC()).Exec()
+	return err
+}
+
+func (m *Migrator) unrecord(s *session.Session, version int64) error {
+	q := s.Dialect().Quote
+	_, err := s.Raw(fmt.Sprintf("DELETE FROM %s WHERE %s = ?", q(m.table), q("version")), version).Exec()
+	return err
+}
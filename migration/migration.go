@@ -0,0 +1,310 @@
+package migration
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"myorm"
+	"myorm/session"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//版本化迁移：每个迁移有一个版本号和升级（up）、降级（down）两个步骤，
+//已经执行过的迁移记录在schema_migrations表中。与Engine.Migrate()自动比较结构体和表不同，
+//版本化迁移可以表达数据迁移、改名等无法从结构体推断出来的修改，适合用来演进生产环境的表结构。
+
+//已执行的迁移被修改过（SQL文件的校验和与执行时不同）
+var ErrChecksumMismatch = errors.New("migration checksum mismatch")
+
+//迁移没有降级步骤，或者已执行的迁移不在注册的迁移中
+var ErrIrreversible = errors.New("migration is irreversible")
+
+//默认的历史表名
+const DefaultTable = "schema_migrations"
+
+// Func 迁移的一个步骤，s处于这个迁移的事务中
+type Func func(s *session.Session) error
+
+// Migration 一个迁移
+type Migration struct {
+	Version int64 //按版本号从小到大执行，常用时间戳，如20240131150405
+	Name    string
+	Up      Func
+	Down    Func //为nil时不能降级
+	//执行时保存到历史表中，之后再执行Up()时比较，不同说明已执行的迁移被修改过。
+	//从SQL文件加载的迁移为升级语句的SHA-256，Go函数的迁移可以自己设置，为空时不比较
+	Checksum string
+}
+
+// Migrator 版本化迁移的执行器
+type Migrator struct {
+	engine     *myorm.Engine
+	table      string
+	migrations []*Migration //按版本号排序
+}
+
+//用法：
+//m := migration.New(engine)
+//m.Register(1, "create_user", up, down)
+//_ = m.LoadFS(os.DirFS("."), "migrations")
+//err := m.Up()
+func New(engine *myorm.Engine) *Migrator {
+	return &Migrator{engine: engine, table: DefaultTable}
+}
+
+//使用其他的历史表名
+func (m *Migrator) WithTable(table string) *Migrator {
+	m.table = table
+	return m
+}
+
+//注册一个用Go函数实现的迁移
+func (m *Migrator) Register(version int64, name string, up, down Func) error {
+	return m.Add(&Migration{Version: version, Name: name, Up: up, Down: down})
+}
+
+//添加迁移，版本号重复时返回错误
+func (m *Migrator) Add(migrations ...*Migration) error {
+	for _, mg := range migrations {
+		if mg.Up == nil {
+			return fmt.Errorf("migration %d %s has no up step", mg.Version, mg.Name)
+		}
+		if old := m.find(mg.Version); old != nil {
+			return fmt.Errorf("duplicate migration version %d: %s and %s", mg.Version, old.Name, mg.Name)
+		}
+		m.migrations = append(m.migrations, mg)
+	}
+	sort.Slice(m.migrations, func(i, j int) bool { return m.migrations[i].Version < m.migrations[j].Version })
+	return nil
+}
+
+//注册的全部迁移，按版本号排序
+func (m *Migrator) Migrations() []*Migration {
+	return m.migrations
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for _, mg := range m.migrations {
+		if mg.Version == version {
+			return mg
+		}
+	}
+	return nil
+}
+
+//SQL文件名：版本号_名字.up.sql、版本号_名字.down.sql
+var fileRegexp = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+//加载fsys中dir目录下的SQL文件，每个版本须有.up.sql文件，.down.sql文件可以没有。
+//一个文件中可以有多条语句，整个文件用一次Exec执行（MySQL须在DSN中设置multiStatements=true）。
+//可以配合embed使用：//go:embed migrations/*.sql
+func (m *Migrator) LoadFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+	type files struct{ name, up, down string }
+	byVersion := make(map[int64]*files)
+	var versions []int64
+	for _, entry := range entries {
+		match := fileRegexp.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid migration file %s: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		f, ok := byVersion[version]
+		if !ok {
+			f = &files{name: match[2]}
+			byVersion[version] = f
+			versions = append(versions, version)
+		}
+		if f.name != match[2] {
+			return fmt.Errorf("migration files of version %d have different names: %s and %s", version, f.name, match[2])
+		}
+		if match[3] == "up" {
+			f.up = string(content)
+		} else {
+			f.down = string(content)
+		}
+	}
+	for _, version := range versions {
+		f := byVersion[version]
+		if f.up == "" {
+			return fmt.Errorf("migration %d %s has no .up.sql file", version, f.name)
+		}
+		mg := &Migration{Version: version, Name: f.name, Up: execSQL(f.up), Checksum: Checksum(f.up)}
+		if f.down != "" {
+			mg.Down = execSQL(f.down)
+		}
+		if err := m.Add(mg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func execSQL(query string) Func {
+	return func(s *session.Session) error {
+		_, err := s.Raw(query).Exec()
+		return err
+	}
+}
+
+//SQL语句的校验和（SHA-256）
+func Checksum(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// Status 一个迁移的状态
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time //执行时间（UTC），未执行时为零值
+	Modified  bool      //已执行，但校验和与执行时不同
+	Missing   bool      //已执行，但不在注册的迁移中（例如文件被删除了）
+}
+
+//全部迁移（包括已执行但没有注册的）的状态，按版本号排序
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var result []Status
+	for _, mg := range m.migrations {
+		st := Status{Version: mg.Version, Name: mg.Name}
+		if rec, ok := applied[mg.Version]; ok {
+			st.Applied, st.AppliedAt = true, rec.AppliedAt
+			st.Modified = rec.Checksum != "" && mg.Checksum != "" && rec.Checksum != mg.Checksum
+		}
+		result = append(result, st)
+	}
+	for _, rec := range applied {
+		if m.find(rec.Version) == nil {
+			result = append(result, Status{Version: rec.Version, Name: rec.Name, Applied: true, AppliedAt: rec.AppliedAt, Missing: true})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+	return result, nil
+}
+
+//执行全部未执行的迁移
+func (m *Migrator) Up() error {
+	return m.up(-1)
+}
+
+//降级版本号最大的n个已执行的迁移
+func (m *Migrator) Down(n int) error {
+	statuses, err := m.checkedStatus()
+	if err != nil {
+		return err
+	}
+	for i := len(statuses) - 1; i >= 0 && n > 0; i-- {
+		if statuses[i].Applied {
+			if err := m.revert(statuses[i]); err != nil {
+				return err
+			}
+			n--
+		}
+	}
+	return nil
+}
+
+//迁移到指定的版本：执行不超过version的未执行迁移，降级超过version的已执行迁移。version为0时降级全部迁移
+func (m *Migrator) To(version int64) error {
+	statuses, err := m.checkedStatus()
+	if err != nil {
+		return err
+	}
+	for i := len(statuses) - 1; i >= 0; i-- {
+		if statuses[i].Applied && statuses[i].Version > version {
+			if err := m.revert(statuses[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return m.up(version)
+}
+
+//执行版本号不超过max的未执行迁移，max<0时执行全部
+func (m *Migrator) up(max int64) error {
+	statuses, err := m.checkedStatus()
+	if err != nil {
+		return err
+	}
+	for _, st := range statuses {
+		if st.Applied || (max >= 0 && st.Version > max) {
+			continue
+		}
+		mg := m.find(st.Version)
+		m.engine.Logger().Infof(context.Background(), "migration %d %s up", mg.Version, mg.Name)
+		err := m.transaction(func(s *session.Session) error {
+			if err := mg.Up(s); err != nil {
+				return err
+			}
+			return m.record(s, mg)
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d %s: %w", mg.Version, mg.Name, err)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) revert(st Status) error {
+	mg := m.find(st.Version)
+	if mg == nil || mg.Down == nil {
+		return fmt.Errorf("%w: %d %s", ErrIrreversible, st.Version, st.Name)
+	}
+	m.engine.Logger().Infof(context.Background(), "migration %d %s down", mg.Version, mg.Name)
+	err := m.transaction(func(s *session.Session) error {
+		if err := mg.Down(s); err != nil {
+			return err
+		}
+		return m.unrecord(s, mg.Version)
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d %s: %w", mg.Version, mg.Name, err)
+	}
+	return nil
+}
+
+//执行或降级前检查已执行的迁移没有被修改过
+func (m *Migrator) checkedStatus() ([]Status, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+	for _, st := range statuses {
+		if st.Modified {
+			return nil, fmt.Errorf("%w: %d %s", ErrChecksumMismatch, st.Version, st.Name)
+		}
+	}
+	return statuses, nil
+}
+
+//每个迁移在自己的事务中执行，失败时回滚这个迁移，之前的迁移不受影响。
+//注意MySQL的DDL语句会隐式提交事务，失败的迁移可能只执行了一部分。
+func (m *Migrator) transaction(f func(s *session.Session) error) error {
+	s := m.engine.Session()
+	_, _, err := s.Transaction(func(s *session.Session) (*session.Session, interface{}, error) {
+		return s, nil, f(s)
+	})
+	return err
+}
@@ -0,0 +1,169 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"myorm"
+	"myorm/log"
+	"myorm/session"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var files = fstest.MapFS{
+	"migrations/1_create_user.up.sql":   {Data: []byte("CREATE TABLE User (Name text PRIMARY KEY, Age integer);")},
+	"migrations/1_create_user.down.sql": {Data: []byte("DROP TABLE User;")},
+	"migrations/2_create_post.up.sql":   {Data: []byte("CREATE TABLE Post (ID integer PRIMARY KEY, Author text);\nCREATE INDEX idx_post_author ON Post (Author);")},
+	"migrations/2_create_post.down.sql": {Data: []byte("DROP INDEX idx_post_author;\nDROP TABLE Post;")},
+	"migrations/README.md":              {Data: []byte("not a migration")},
+}
+
+func newMigrator(t *testing.T) (*myorm.Engine, *Migrator) {
+	t.Helper()
+	engine, err := myorm.NewEngine("sqlite3", filepath.Join(t.TempDir(), "myorm.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(engine.Close)
+	m := New(engine)
+	if err := m.LoadFS(files, "migrations"); err != nil {
+		t.Fatal(err)
+	}
+	//数据迁移：没有降级步骤
+	err = m.Register(3, "seed_admin", func(s *session.Session) error {
+		if _, err := s.Raw("INSERT INTO User (Name, Age) VALUES (?, ?)", "admin", 30).Exec(); err != nil {
+			return err
+		}
+		_, err := s.Raw("INSERT INTO Post (Author) VALUES (?)", "admin").Exec()
+		return err
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return engine, m
+}
+
+func applied(t *testing.T, m *Migrator) []int64 {
+	t.Helper()
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []int64
+	for _, st := range statuses {
+		if st.Applied {
+			versions = append(versions, st.Version)
+		}
+	}
+	return versions
+}
+
+func TestMigrator_UpDown(t *testing.T) {
+	engine, m := newMigrator(t)
+	if err := m.To(2); err != nil {
+		t.Fatal(err)
+	}
+	if got := applied(t, m); len(got) != 2 {
+		t.Fatal("To(2) should apply 2 migrations", got)
+	}
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	_ = engine.NewSession().Raw("SELECT count(*) FROM Post WHERE Author = 'admin'").QueryRow().Scan(&count)
+	if count != 1 || len(applied(t, m)) != 3 {
+		t.Fatal("failed to apply all migrations")
+	}
+
+	if err := m.Down(1); !errors.Is(err, ErrIrreversible) {
+		t.Fatal("seed_admin has no down step", err)
+	}
+	//只回滚不能降级的迁移之后的部分
+	m.Migrations()[2].Down = func(s *session.Session) error {
+		_, err := s.Raw("DELETE FROM User").Exec()
+		return err
+	}
+	if err := m.Down(2); err != nil {
+		t.Fatal(err)
+	}
+	if got := applied(t, m); len(got) != 1 || got[0] != 1 {
+		t.Fatal("Down(2) should revert 3 and 2", got)
+	}
+	if err := m.To(0); err != nil {
+		t.Fatal(err)
+	}
+	if tables, _ := engine.Tables(); len(tables) != 1 || tables[0] != DefaultTable {
+		t.Fatal("To(0) should revert all migrations", tables)
+	}
+}
+
+func TestMigrator_FailedMigration(t *testing.T) {
+	_, m := newMigrator(t)
+	_ = m.Register(4, "broken", func(s *session.Session) error {
+		if _, err := s.Raw("INSERT INTO User (Name) VALUES ('tmp')").Exec(); err != nil {
+			return err
+		}
+		_, err := s.Raw("INSERT INTO Missing VALUES (1)").Exec()
+		return err
+	}, nil)
+	if err := m.Up(); err == nil {
+		t.Fatal("broken migration should fail")
+	}
+	//之前的迁移已经提交，失败的迁移整个回滚
+	if got := applied(t, m); len(got) != 3 {
+		t.Fatal("migrations before the broken one should be applied", got)
+	}
+	var count int
+	_ = m.engine.NewSession().Raw("SELECT count(*) FROM User WHERE Name = 'tmp'").QueryRow().Scan(&count)
+	if count != 0 {
+		t.Fatal("broken migration should be rolled back")
+	}
+}
+
+func TestMigrator_Checksum(t *testing.T) {
+	engine, m := newMigrator(t)
+	if err := m.To(1); err != nil {
+		t.Fatal(err)
+	}
+	//已执行的迁移文件被修改了
+	changed := New(engine)
+	_ = changed.LoadFS(fstest.MapFS{
+		"m/1_create_user.up.sql": {Data: []byte("CREATE TABLE User (Name text PRIMARY KEY);")},
+		"m/2_create_post.up.sql": files["migrations/2_create_post.up.sql"],
+	}, "m")
+	statuses, _ := changed.Status()
+	if !statuses[0].Modified || statuses[1].Applied {
+		t.Fatal("failed to detect modified migration", statuses)
+	}
+	if err := changed.Up(); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatal("modified migration should block Up", err)
+	}
+	//已执行的迁移文件被删除了
+	missing := New(engine)
+	statuses, _ = missing.Status()
+	if len(statuses) != 1 || !statuses[0].Missing || statuses[0].Name != "create_user" {
+		t.Fatal("failed to report missing migration", statuses)
+	}
+}
+
+func TestMigrator_SlowQuery(t *testing.T) {
+	engine, m := newMigrator(t)
+	var slow []string
+	engine.SetSlowQuery(time.Nanosecond, func(ctx context.Context, q *log.SlowQuery) {
+		slow = append(slow, q.SQL)
+	})
+	if err := m.To(1); err != nil {
+		t.Fatal(err)
+	}
+	//迁移中的语句和schema_migrations表的读写都按引擎的阈值检查
+	found := false
+	for _, sql := range slow {
+		found = found || strings.HasPrefix(sql, "CREATE TABLE User")
+	}
+	if !found {
+		t.Fatal("migration statements should be checked for slow queries", slow)
+	}
+}
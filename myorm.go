@@ -1,12 +1,13 @@
 package myorm
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	_ "github.com/mattn/go-sqlite3"
 	"myorm/dialect"
 	"myorm/log"
 	"myorm/session"
-	"strings"
+	"time"
 )
 
 type Engine struct {
@@ -14,16 +15,69 @@ type Engine struct {
 	dialectSQL dialect.Dialect
 	defaultSession *session.Session //一个引擎可以产生多个会话，此处保存一个默认会话
 	sessionQueue []*session.Session //引擎产生的多个会话都保存到这个切片里
+	retryPolicy *RetryPolicy //事务的重试策略，为nil时不重试
+	logger log.Logger //为nil时使用log.Default()
+	slowThreshold time.Duration //慢查询的阈值，为0时不检查
+	onSlowQuery session.SlowQueryFunc //慢查询的回调
 }
-/*
-func (engine *Engine)DB() *sql.DB {
+//引擎的数据库连接池
+func (engine *Engine) DB() *sql.DB {
 	return engine.db
-}*/
+}
+
+//设置引擎的Logger，之后引擎产生的会话都使用它。l为nil时使用log.Default()
+func (engine *Engine) SetLogger(l log.Logger) {
+	engine.logger = l
+}
+
+//引擎的Logger，没有设置时返回log.Default()
+func (engine *Engine) Logger() log.Logger {
+	if engine.logger == nil {
+		return log.Default()
+	}
+	return engine.logger
+}
+
+//按引擎的设置（Logger、慢查询）建立会话。与NewSession()不同，它不放进sessionQueue，
+//适合框架内部（如myorm/migration）执行一次性的语句
+func (engine *Engine) Session() *session.Session {
+	return session.New(engine.db, engine.dialectSQL).WithLogger(engine.logger).WithSlowQuery(engine.slowThreshold, engine.onSlowQuery)
+}
+
+//设置慢查询的阈值，之后引擎产生的会话中执行时间超过threshold的语句会连同参数、用时和调用位置
+//以Warn级别写到日志，并调用callback（可以为nil）。threshold为0时关闭（默认关闭）。
+//用法：engine.SetSlowQuery(200*time.Millisecond, func(ctx context.Context, q *log.SlowQuery) { metrics.Inc(q.Caller) })
+func (engine *Engine) SetSlowQuery(threshold time.Duration, callback session.SlowQueryFunc) {
+	engine.slowThreshold, engine.onSlowQuery = threshold, callback
+}
+
+//引擎使用的方言
+func (engine *Engine) Dialect() dialect.Dialect {
+	return engine.dialectSQL
+}
 
 func NewEngine(driver, source string) (e *Engine, err error) {
+	return NewEngineWithLogger(driver, source, nil)
+}
+
+//与NewEngine()相同，但引擎使用logger（为nil时使用log.Default()），连接失败的错误也写到logger
+func NewEngineWithLogger(driver, source string, logger log.Logger) (e *Engine, err error) {
+	l, ctx := logger, context.Background()
+	if l == nil {
+		l = log.Default()
+	}
+	dial,ok:=dialect.GetDialect(driver)
+	if !ok{
+		err = fmt.Errorf("dialect %s Not Found", driver)
+		l.Errorf(ctx, "%v", err)
+		return
+	}
+	if d, ok := dial.(dialect.DataSourceDialect); ok {
+		source = d.DataSource(source)
+	}
 	db, err := sql.Open(driver, source)
 	if err != nil {
-		log.Error(err)
+		l.Errorf(ctx, "%v", err)
 		return
 	}
 	// Send a ping to make sure the database connection is alive.
@@ -31,25 +85,45 @@ func NewEngine(driver, source string) (e *Engine, err error) {
 	//并没有真正的连接到数据库中，在后续的对数据库的操作中才会真正去网络连接，
 	//如果要马上验证，可以用 db.ping().
 	if err = db.Ping(); err != nil {
-		log.Error(err)
+		l.Errorf(ctx, "%v", err)
+		_ = db.Close()
 		return
 	}
-	dial,ok:=dialect.GetDialect(driver)
-	if !ok{
-		log.Errorf("dialect %s Not Found", driver)
-		return
+	//根据数据库的版本确定它支持的特性
+	if d, ok := dial.(dialect.DetectingDialect); ok {
+		if dial, err = d.Detect(db); err != nil {
+			l.Errorf(ctx, "%v", err)
+			_ = db.Close()
+			return
+		}
 	}
 	sessionQueue0:=make([]*session.Session,0)
-	e = &Engine{db: db,dialectSQL:dial,sessionQueue:sessionQueue0}
-	log.Info("Connect database success")
+	e = &Engine{db: db,dialectSQL:dial,sessionQueue:sessionQueue0,logger:logger}
+	l.Infof(ctx, "Connect database success")
 	return
 }
 
 func (engine *Engine) Close() {
 	if err := engine.db.Close(); err != nil {
-		log.Error("Failed to close database")
+		engine.Logger().Errorf(context.Background(), "Failed to close database: %v", err)
+		return
 	}
-	log.Info("Close database success")
+	engine.Logger().Infof(context.Background(), "Close database success")
+}
+
+//数据库支持的特性
+func (engine *Engine) Capabilities() dialect.Capabilities {
+	return engine.dialectSQL.Capabilities()
+}
+
+//数据库中的全部表名
+func (engine *Engine) Tables() ([]string, error) {
+	return engine.Session().Tables()
+}
+
+//读取数据库中一个表的结构，包括列的类型、是否可空、默认值、主键，以及索引和外键
+func (engine *Engine) Inspect(table string) (*dialect.TableInfo, error) {
+	return engine.Session().Inspect(table)
 }
 
 func (engine *Engine) DefaultSession() *session.Session {
@@ -61,7 +135,7 @@ func (engine *Engine) SessionQueue() []*session.Session {
 }
 
 func (engine *Engine) NewSession() *session.Session {
-	result:=session.New(engine.db,engine.dialectSQL)
+	result:=engine.Session()
 	engine.sessionQueue=append(engine.sessionQueue,result)
 	if engine.defaultSession==nil{
 		engine.defaultSession=result
@@ -79,6 +153,14 @@ type TxFunc func(*session.Session) (interface{}, error)
 //		return nil, errors.New("Error")
 //	})
 func (engine *Engine) Transaction(f TxFunc) (result interface{}, err error) {
+	return engine.TransactionWithOptions(nil, f)
+}
+
+//与Transaction()相同，但按照opts开启事务，例如写事务较多的SQLite数据库可以使用
+//&session.TxOptions{Mode: "IMMEDIATE"}，在事务开始时就申请写锁，避免读锁升级为写锁时的死锁。
+//设置了重试策略（SetRetryPolicy）时，因暂时性错误失败的事务会被回滚并从头重新执行。
+//嵌套在其他事务中的Transaction()不会重试，由最外层的事务负责。
+func (engine *Engine) TransactionWithOptions(opts *session.TxOptions, f TxFunc) (result interface{}, err error) {
 	var s *session.Session
 	if engine.defaultSession!=nil{
 		s = engine.defaultSession
@@ -86,7 +168,19 @@ func (engine *Engine) Transaction(f TxFunc) (result interface{}, err error) {
 		s = engine.NewSession()
 	}
 
-	if err := s.Begin(); err != nil { //开启一个事务。s.Begin()表示新建一个事务并将其指针保存到s中。
+	if s.TxDepth() > 0 {
+		return engine.transaction(s, opts, f)
+	}
+	err = engine.retry(s, func() error {
+		result, err = engine.transaction(s, opts, f)
+		return err
+	})
+	return
+}
+
+//执行一次事务
+func (engine *Engine) transaction(s *session.Session, opts *session.TxOptions, f TxFunc) (result interface{}, err error) {
+	if err := s.BeginTx(opts); err != nil { //开启一个事务。s.BeginTx()表示新建一个事务并将其指针保存到s中。
 		return nil, err
 	}
 	//Recover 是一个Go语言的内建函数，可以让进入宕机流程中的 goroutine 恢复过来，
@@ -133,59 +227,3 @@ func (s *session.Session) Transaction(f TxFunc) (result interface{}, err error)
 	//先执行f(s)、后执行defer func()，最后再返回。
 }
  */
-
-func difference(a []string, b []string) (diff []string) {
-	mapB := make(map[string]bool)
-	for _, v := range b {
-		mapB[v] = true
-	}
-	for _, v := range a {
-		if _, ok := mapB[v]; !ok {
-			diff = append(diff, v)
-		}
-	}
-	return
-}
-
-// Migrate table
-func (engine *Engine) Migrate(value interface{}) error {
-	//value是新的结构体，本函数须根据value更新表框架
-	_, err := engine.Transaction(func(s *session.Session) (result interface{}, err error) {
-		// s.Model(value)表示将根据value建立一个表框架并定为该会话的refTable（更新了refTable）
-		// 先更新表框架（s.Model(value)），再更新表。
-		if !s.Model(value).HasTable() { // 如果本来就没有表框架，新建一个即可
-			log.Infof("table %s doesn't exist", s.RefTable().Name)
-			return nil, s.CreateTable()
-		}
-		// 下面才是更新表的部分。
-		table := s.RefTable()
-		rows, _ := s.Raw(fmt.Sprintf("SELECT * FROM %s LIMIT 1", table.Name)).QueryRows()
-		columns, _ := rows.Columns() //根据查询结果，调用系统SQL库，获得属性名的集合
-		addCols := difference(table.FieldNames, columns)
-		delCols := difference(columns, table.FieldNames)
-		log.Infof("added cols %v, deleted cols %v", addCols, delCols)
-
-		for _, col := range addCols {
-			f := table.GetField(col)
-			sqlStr := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", table.Name, f.Name, f.Type)
-			if _, err = s.Raw(sqlStr).Exec(); err != nil {
-				return
-			}
-		}
-
-		if len(delCols) == 0 {
-			return
-		}
-		tmp := "tmp_" + table.Name
-		fieldStr := strings.Join(table.FieldNames, ", ")
-		//创建一个新表并从旧表中选取需要的属性
-		s.Raw(fmt.Sprintf("CREATE TABLE %s AS SELECT %s from %s;", tmp, fieldStr, table.Name))
-		//删除旧表
-		s.Raw(fmt.Sprintf("DROP TABLE %s;", table.Name))
-		//将新表重命名
-		s.Raw(fmt.Sprintf("ALTER TABLE %s RENAME TO %s;", tmp, table.Name))
-		_, err = s.Exec()
-		return
-	})
-	return err
-}
\ No newline at end of file
@@ -0,0 +1,182 @@
+package myorm
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"myorm/log"
+	"myorm/session"
+)
+
+type User struct {
+	Name string `myorm:"PRIMARY KEY"`
+	Age  int
+}
+
+func OpenDB(t *testing.T) *Engine {
+	t.Helper()
+	engine, err := NewEngine("sqlite3", filepath.Join(t.TempDir(), "myorm.db")+"?_busy_timeout=0")
+	if err != nil {
+		t.Fatal("failed to connect", err)
+	}
+	t.Cleanup(engine.Close)
+	return engine
+}
+
+//另一个会话持有写锁一段时间后释放
+func holdLock(t *testing.T, engine *Engine, d time.Duration) {
+	locker := engine.NewSession()
+	if err := locker.BeginTx(&session.TxOptions{Mode: "IMMEDIATE"}); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(d)
+		_ = locker.Rollback()
+	}()
+}
+
+func TestEngine_TransactionRetry(t *testing.T) {
+	engine := OpenDB(t)
+	s := engine.NewSession().Model(&User{})
+	_ = s.CreateTable()
+	insert := func(s *session.Session) (interface{}, error) {
+		return s.Insert(&User{"Tom", 18})
+	}
+
+	holdLock(t, engine, 50*time.Millisecond)
+	if _, err := engine.Transaction(insert); err == nil {
+		t.Fatal("expected database is locked")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	holdLock(t, engine, 50*time.Millisecond)
+	engine.SetRetryPolicy(&RetryPolicy{MaxAttempts: 10, BaseDelay: 10 * time.Millisecond, MaxDelay: 40 * time.Millisecond})
+	if _, err := engine.Transaction(insert); err != nil {
+		t.Fatal("failed to retry transaction", err)
+	}
+	if n, _ := s.Count(); n != 1 {
+		t.Fatal("expected 1 record, got", n)
+	}
+
+	//迁移也按重试策略重试
+	time.Sleep(100 * time.Millisecond)
+	holdLock(t, engine, 50*time.Millisecond)
+	if err := engine.Migrate(&Product{}); err != nil {
+		t.Fatal("failed to retry migration", err)
+	}
+}
+
+func TestEngine_Inspect(t *testing.T) {
+	engine := OpenDB(t)
+	s := engine.NewSession()
+	for _, stmt := range []string{
+		"CREATE TABLE Team (ID integer PRIMARY KEY, Name text NOT NULL DEFAULT 'none')",
+		"CREATE TABLE Member (ID integer PRIMARY KEY, TeamID integer REFERENCES Team(ID) ON DELETE CASCADE, Email text UNIQUE, Age int)",
+		"CREATE INDEX idx_member_team_age ON Member (TeamID, Age) WHERE Age > 0",
+	} {
+		if _, err := s.Raw(stmt).Exec(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tables, _ := engine.Tables(); !reflect.DeepEqual(tables, []string{"Member", "Team"}) {
+		t.Fatal("failed to list tables", tables)
+	}
+	team, err := engine.Inspect("Team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := team.Columns[1]
+	if !team.Columns[0].PrimaryKey || name.Type != "text" || !name.NotNull || name.Default == nil || *name.Default != "'none'" {
+		t.Fatal("failed to inspect columns", team.Columns)
+	}
+	member, _ := engine.Inspect("Member")
+	if len(member.Indexes) != 2 {
+		t.Fatal("failed to inspect indexes", member.Indexes)
+	}
+	if idx := member.Indexes[0]; idx.Name != "idx_member_team_age" || !idx.Partial || idx.Unique ||
+		!reflect.DeepEqual(idx.Columns, []string{"TeamID", "Age"}) {
+		t.Fatal("failed to inspect partial index", idx)
+	}
+	if idx := member.Indexes[1]; !idx.Unique || !reflect.DeepEqual(idx.Columns, []string{"Email"}) {
+		t.Fatal("failed to inspect unique index", idx)
+	}
+	fk := member.ForeignKeys
+	if len(fk) != 1 || fk[0].RefTable != "Team" || fk[0].Columns[0] != "TeamID" || fk[0].RefColumns[0] != "ID" || fk[0].OnDelete != "CASCADE" {
+		t.Fatal("failed to inspect foreign keys", fk)
+	}
+	if _, err := engine.Inspect("Nobody"); err == nil {
+		t.Fatal("expected table doesn't exist")
+	}
+}
+
+// countLogger 统计SQL语句的条数
+type countLogger struct {
+	log.Logger
+	statements int
+}
+
+func (l *countLogger) Trace(ctx context.Context, t *log.Trace) {
+	l.statements++
+}
+
+func TestEngine_SetLogger(t *testing.T) {
+	engine := OpenDB(t)
+	l := &countLogger{Logger: log.Default()}
+	engine.SetLogger(l)
+	if _, err := engine.NewSession().Raw("SELECT 1").Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.Tables(); err != nil {
+		t.Fatal(err)
+	}
+	if l.statements != 2 {
+		t.Fatal("expected 2 statements logged by engine logger, got", l.statements)
+	}
+}
+
+//连接失败的错误写到引擎的Logger而不是默认的Logger
+type errorLogger struct {
+	log.Logger
+	errors []string
+}
+
+func (l *errorLogger) Errorf(ctx context.Context, format string, v ...interface{}) {
+	l.errors = append(l.errors, fmt.Sprintf(format, v...))
+}
+
+func TestNewEngineWithLogger(t *testing.T) {
+	l := &errorLogger{Logger: log.Default()}
+	if _, err := NewEngineWithLogger("nosql", "", l); err == nil || len(l.errors) != 1 {
+		t.Fatal("expected the error logged by the engine logger", err, l.errors)
+	}
+	engine, err := NewEngineWithLogger("sqlite3", filepath.Join(t.TempDir(), "myorm.db"), l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+	if engine.Logger() != l {
+		t.Fatal("engine should use the logger passed to NewEngineWithLogger")
+	}
+}
+
+func TestEngine_SetSlowQuery(t *testing.T) {
+	engine := OpenDB(t)
+	var slow []string
+	engine.SetSlowQuery(time.Nanosecond, func(ctx context.Context, q *log.SlowQuery) {
+		slow = append(slow, q.SQL)
+	})
+	if _, err := engine.NewSession().Raw("SELECT ?", 1).Exec(); err != nil {
+		t.Fatal(err)
+	}
+	engine.SetSlowQuery(0, nil)
+	if _, err := engine.NewSession().Raw("SELECT 2").Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(slow, []string{"SELECT ? "}) {
+		t.Fatal("unexpected slow queries", slow)
+	}
+}
@@ -0,0 +1,69 @@
+package myorm
+
+import (
+	"math/rand"
+	"myorm/dialect"
+	"myorm/session"
+	"time"
+)
+
+//事务的重试策略。
+//并发写入时，事务可能因为暂时性的错误（如SQLite的"database is locked"）而失败，
+//这时回滚并从头重新执行整个TxFunc通常就能成功。
+//注意：TxFunc可能被执行多次，其中不应有事务之外的副作用。
+type RetryPolicy struct {
+	MaxAttempts int           //最多执行几次（包括第一次），小于2时不重试
+	BaseDelay   time.Duration //第一次重试前的等待时间，之后每次翻倍
+	MaxDelay    time.Duration //等待时间的上限，为0时不设上限
+	//判断错误是否可以重试，为nil时使用方言的判断（方言实现了dialect.RetryableDialect时）
+	Retryable func(err error) bool
+}
+
+//设置引擎执行Transaction()时的重试策略，传入nil关闭重试（默认关闭）。
+//用法：engine.SetRetryPolicy(&myorm.RetryPolicy{MaxAttempts: 5, BaseDelay: 10 * time.Millisecond})
+func (engine *Engine) SetRetryPolicy(policy *RetryPolicy) {
+	engine.retryPolicy = policy
+}
+
+//按引擎的重试策略执行一个事务do：因暂时性错误失败时等待一段时间后从头重新执行，没有设置策略时只执行一次
+func (engine *Engine) retry(s *session.Session, do func() error) error {
+	p := engine.retryPolicy
+	for attempt := 1; ; attempt++ {
+		err := do()
+		if err == nil || p == nil || attempt >= p.MaxAttempts || !p.retryable(engine.dialectSQL, err) {
+			return err
+		}
+		delay := p.backoff(attempt)
+		s.Logger().Infof(s.Context(), "transaction failed (%v), retry %d/%d after %v", err, attempt, p.MaxAttempts-1, delay)
+		select {
+		case <-time.After(delay):
+		case <-s.Context().Done():
+			return err
+		}
+	}
+}
+
+//使用策略中的判断函数或方言判断错误是否可以重试
+func (p *RetryPolicy) retryable(d dialect.Dialect, err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	if rd, ok := d.(dialect.RetryableDialect); ok {
+		return rd.IsRetryable(err)
+	}
+	return false
+}
+
+//第attempt次重试前的等待时间：指数退避，并在[delay/2, delay]之间随机抖动，
+//避免多个同时失败的事务又同时重试。
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << uint(attempt-1)
+	if delay <= 0 || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
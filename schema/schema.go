@@ -1,17 +1,77 @@
 package schema
 
 import (
+	"fmt"
 	"myorm/dialect"
 	"go/ast"
 	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 // Field represents a column of database
 //Field:字段，对应数据库中的一个属性（一列），包含列名、类型和注解
 type Field struct {
-	Name string
-	Type string
-	Tag  string
+	Name          string //列名，默认与结构体的字段名相同，可以用column:指定
+	GoName        string //结构体的字段名
+	Type          string
+	Tag           string
+	AutoIncrement bool   //自增列，建表时由方言加上对应的关键字
+	RenamedFrom   string //字段改名前的列名，迁移时把旧列改名而不是删除旧列、新增一列
+	Sensitive     bool   //敏感字段（密码、令牌等），日志中遮盖它的值
+}
+
+//字段是否为主键（注解中含有PRIMARY KEY）
+func (f *Field) IsPrimaryKey() bool {
+	return strings.Contains(strings.ToUpper(f.Tag), "PRIMARY KEY")
+}
+
+//字段是否不能为空（注解中含有NOT NULL）
+func (f *Field) IsNotNull() bool {
+	return strings.Contains(strings.ToUpper(f.Tag), "NOT NULL")
+}
+
+//字段的值是否唯一（注解中含有UNIQUE）
+func (f *Field) IsUnique() bool {
+	return strings.Contains(strings.ToUpper(f.Tag), "UNIQUE")
+}
+
+var defaultRegexp = regexp.MustCompile(`(?i)\bDEFAULT\s+('(?:[^']|'')*'|\([^)]*\)|[^\s,]+)`)
+
+//字段的默认值（注解中DEFAULT之后的表达式），没有默认值时返回nil
+func (f *Field) DefaultValue() *string {
+	m := defaultRegexp.FindStringSubmatch(f.Tag)
+	if m == nil {
+		return nil
+	}
+	return &m[1]
+}
+
+// Index 注解中声明的索引
+type Index struct {
+	Name    string
+	Unique  bool
+	Columns []string //按priority从小到大排列，相同时按字段的顺序
+	Where   string   //部分索引的条件，为空时是普通索引
+}
+
+//一个字段注解中的index/uniqueIndex，同名的合并成一个索引
+type indexPart struct {
+	Index
+	column   string
+	priority int
+}
+
+// ForeignKey 注解中声明的外键
+type ForeignKey struct {
+	Name       string
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+	OnDelete   string //CASCADE、SET NULL、SET DEFAULT、RESTRICT或NO ACTION，为空时是数据库的默认动作NO ACTION
+	OnUpdate   string
 }
 
 // Schema represents a table of database
@@ -19,11 +79,16 @@ type Field struct {
 //包含程序中的对应模型、表名、各字段信息、全体列名和列名→字段的映射
 //Fields包含了所有字段的所有信息，FieldNames和fieldMap是冗余的。
 type Schema struct {
-	Model      interface{}
-	Name       string
-	Fields     []*Field
-	FieldNames []string
-	fieldMap   map[string]*Field
+	Model        interface{}
+	Name         string
+	Fields       []*Field
+	FieldNames   []string
+	fieldMap     map[string]*Field
+	PrimaryField *Field   //主键字段，没有主键时为nil；联合主键时是第一个主键字段
+	PrimaryKeys  []*Field //全部主键字段，多于一个时是联合主键，建表时写成表约束PRIMARY KEY (a, b)
+	VersionField *Field //乐观锁的版本号字段，没有时为nil
+	Indexes      []*Index
+	ForeignKeys  []*ForeignKey
 }
 
 //根据名字获得字段
@@ -31,7 +96,13 @@ func (schema *Schema) GetField(name string) *Field {
 	return schema.fieldMap[name]
 }
 
-//传入一个结构体的实例和方言，建立一个与该结构体对应的表框架（Schema）
+// Tabler 模型实现它时用TableName()作为表名，否则表名为结构体名
+type Tabler interface {
+	TableName() string
+}
+
+//传入一个结构体的实例和方言，建立一个与该结构体对应的表框架（Schema）。
+//指针类型的字段对应可以为NULL的列，列的类型与指向的类型相同
 func Parse(dest interface{}, d dialect.Dialect) *Schema {
 	modelType := reflect.Indirect(reflect.ValueOf(dest)).Type()
 	schema := &Schema{
@@ -39,31 +110,229 @@ func Parse(dest interface{}, d dialect.Dialect) *Schema {
 		Name:     modelType.Name(),
 		fieldMap: make(map[string]*Field),
 	}
+	if tabler, ok := reflect.New(modelType).Interface().(Tabler); ok {
+		schema.Name = tabler.TableName()
+	}
 
+	var parts []indexPart
+	var fkParts []*ForeignKey
 	for i := 0; i < modelType.NumField(); i++ {
 		p := modelType.Field(i)
 		if !p.Anonymous && ast.IsExported(p.Name) {
+			typ := p.Type
+			if typ.Kind() == reflect.Ptr {
+				typ = typ.Elem()
+			}
 			field := &Field{
-				Name: p.Name,
-				Type: d.DataTypeOf(reflect.Indirect(reflect.New(p.Type))),
+				Name:   p.Name,
+				GoName: p.Name,
+				Type:   d.DataTypeOf(reflect.Indirect(reflect.New(typ))),
 			}
 			if v, ok := p.Tag.Lookup("myorm"); ok {
-				field.Tag = v
+				indexes, fk := schema.parseTag(field, v)
+				if schema.VersionField == field && !isInteger(p.Type.Kind()) {
+					panic(fmt.Sprintf("invalid version field %s.%s: should be an integer", schema.Name, p.Name))
+				}
+				parts = append(parts, indexes...)
+				if fk != nil {
+					fkParts = append(fkParts, fk)
+				}
+			}
+			if field.IsPrimaryKey() {
+				if schema.PrimaryField == nil {
+					schema.PrimaryField = field
+				}
+				schema.PrimaryKeys = append(schema.PrimaryKeys, field)
 			}
 			schema.Fields = append(schema.Fields, field)
-			schema.FieldNames = append(schema.FieldNames, p.Name)
-			schema.fieldMap[p.Name] = field
+			schema.FieldNames = append(schema.FieldNames, field.Name)
+			schema.fieldMap[field.Name] = field
 		}
 	}
+	schema.Indexes = mergeIndexes(parts)
+	schema.ForeignKeys = mergeForeignKeys(fkParts)
 	return schema
 }
 
+//版本号字段须是整数，Save时才能在实例中加一
+func isInteger(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+//解析注解。注解由";"分隔成若干部分，例如`myorm:"NOT NULL;version"`。
+//其中的关键字由框架自己处理，其余部分原样作为列约束写入建表语句：
+//column:xxx：列名；version：乐观锁的版本号，须是整数字段；autoIncrement：自增列；type:xxx：指定列的类型，如type:varchar(64)；renamedFrom:旧列名；
+//sensitive：敏感字段，插入、更新时它的值不会出现在日志中；
+//index、uniqueIndex：字段上的索引，见parseIndex；foreignKey、references、constraint：外键，见parseForeignKey
+func (schema *Schema) parseTag(field *Field, tag string) (parts []indexPart, fk *ForeignKey) {
+	var constraints []string
+	var fkOptions map[string]string
+	split := func(part string) (key, value string) {
+		key = part
+		if i := strings.Index(part, ":"); i >= 0 {
+			key, value = strings.TrimSpace(part[:i]), strings.TrimSpace(part[i+1:])
+		}
+		return
+	}
+	//索引、外键的默认名字用到列名，先确定列名
+	for _, part := range strings.Split(tag, ";") {
+		if key, value := split(strings.TrimSpace(part)); strings.EqualFold(key, "column") && value != "" {
+			field.Name = value
+		}
+	}
+	for _, part := range strings.Split(tag, ";") {
+		part = strings.TrimSpace(part)
+		key, value := split(part)
+		switch strings.ToLower(key) {
+		case "", "column":
+		case "version":
+			schema.VersionField = field
+		case "autoincrement":
+			field.AutoIncrement = true
+		case "sensitive":
+			field.Sensitive = true
+		case "renamedfrom":
+			field.RenamedFrom = value
+		case "type":
+			field.Type = value
+		case "index", "uniqueindex":
+			parts = append(parts, schema.parseIndex(field, strings.EqualFold(key, "uniqueIndex"), value))
+		case "foreignkey", "references", "constraint":
+			if fkOptions == nil {
+				fkOptions = make(map[string]string)
+			}
+			fkOptions[strings.ToLower(key)] = value
+		default:
+			constraints = append(constraints, part)
+		}
+	}
+	field.Tag = strings.Join(constraints, " ")
+	if fkOptions != nil {
+		fk = schema.parseForeignKey(field, fkOptions)
+	}
+	return
+}
+
+var refRegexp = regexp.MustCompile(`^\s*(\w+)\s*\(\s*(\w+)\s*\)\s*$`)
+
+var fkActions = map[string]bool{"CASCADE": true, "SET NULL": true, "SET DEFAULT": true, "RESTRICT": true, "NO ACTION": true}
+
+//foreignKey:fk_name;references:Product(ID);constraint:OnDelete:CASCADE,OnUpdate:SET NULL
+//外键名默认为fk_表名_列名，可以省略foreignKey；名字相同的外键合并为多列外键，列按字段的顺序排列。
+//注解写错时panic，与不支持的字段类型一样，在程序启动时就能发现
+func (schema *Schema) parseForeignKey(field *Field, options map[string]string) *ForeignKey {
+	fk := &ForeignKey{Name: options["foreignkey"], Columns: []string{field.Name}}
+	if fk.Name == "" {
+		fk.Name = "fk_" + schema.Name + "_" + field.Name
+	}
+	m := refRegexp.FindStringSubmatch(options["references"])
+	if m == nil {
+		panic(fmt.Sprintf("invalid foreign key on %s.%s: references should be like Table(Column)", schema.Name, field.Name))
+	}
+	fk.RefTable, fk.RefColumns = m[1], []string{m[2]}
+	if constraint := options["constraint"]; constraint != "" {
+		for _, opt := range strings.Split(constraint, ",") {
+			i := strings.Index(opt, ":")
+			action := strings.ToUpper(strings.Join(strings.Fields(opt[i+1:]), " "))
+			if i < 0 || !fkActions[action] {
+				panic(fmt.Sprintf("invalid foreign key constraint on %s.%s: %s", schema.Name, field.Name, opt))
+			}
+			switch strings.ToLower(strings.TrimSpace(opt[:i])) {
+			case "ondelete":
+				fk.OnDelete = action
+			case "onupdate":
+				fk.OnUpdate = action
+			default:
+				panic(fmt.Sprintf("invalid foreign key constraint on %s.%s: %s", schema.Name, field.Name, opt))
+			}
+		}
+	}
+	return fk
+}
+
+func mergeForeignKeys(parts []*ForeignKey) []*ForeignKey {
+	var fks []*ForeignKey
+	byName := make(map[string]*ForeignKey)
+	for _, part := range parts {
+		if fk, ok := byName[part.Name]; ok {
+			fk.Columns = append(fk.Columns, part.Columns...)
+			fk.RefColumns = append(fk.RefColumns, part.RefColumns...)
+			if fk.OnDelete == "" {
+				fk.OnDelete = part.OnDelete
+			}
+			if fk.OnUpdate == "" {
+				fk.OnUpdate = part.OnUpdate
+			}
+			continue
+		}
+		byName[part.Name] = part
+		fks = append(fks, part)
+	}
+	return fks
+}
+
+//index:idx_name,priority:2,where:Age > 18
+//索引名默认为idx_表名_列名；名字相同的索引合并为多列索引，列按priority（默认10）排列；
+//where:之后直到注解结束都是部分索引的条件，可以包含逗号
+func (schema *Schema) parseIndex(field *Field, unique bool, value string) indexPart {
+	part := indexPart{Index: Index{Name: "idx_" + schema.Name + "_" + field.Name, Unique: unique}, column: field.Name, priority: 10}
+	for value != "" {
+		opt, rest := value, ""
+		if i := strings.Index(value, ","); i >= 0 {
+			opt, rest = value[:i], value[i+1:]
+		}
+		key, v := strings.TrimSpace(opt), ""
+		if i := strings.Index(opt, ":"); i >= 0 {
+			key, v = strings.TrimSpace(opt[:i]), strings.TrimSpace(opt[i+1:])
+		}
+		switch strings.ToLower(key) {
+		case "priority":
+			part.priority, _ = strconv.Atoi(v)
+		case "where":
+			part.Where = strings.TrimSpace(value[strings.Index(value, ":")+1:])
+			rest = ""
+		case "":
+		default:
+			part.Name = key
+		}
+		value = rest
+	}
+	return part
+}
+
+//索引按第一次出现的顺序排列，索引中的列按priority排列
+func mergeIndexes(parts []indexPart) []*Index {
+	var indexes []*Index
+	byName := make(map[string]*Index)
+	for _, part := range parts {
+		if _, ok := byName[part.Name]; !ok {
+			byName[part.Name] = &Index{Name: part.Name}
+			indexes = append(indexes, byName[part.Name])
+		}
+	}
+	sort.SliceStable(parts, func(i, j int) bool { return parts[i].priority < parts[j].priority })
+	for _, part := range parts {
+		idx := byName[part.Name]
+		idx.Unique = idx.Unique || part.Unique
+		if idx.Where == "" {
+			idx.Where = part.Where
+		}
+		idx.Columns = append(idx.Columns, part.column)
+	}
+	return indexes
+}
+
 //{"amy",19}转化成["amy",19]
 func (schema *Schema) RecordValues(dest interface{}) []interface{} {
 	destValue := reflect.Indirect(reflect.ValueOf(dest))
 	var fieldValues []interface{}
 	for _, field := range schema.Fields {
-		fieldValues = append(fieldValues, destValue.FieldByName(field.Name).Interface())
+		fieldValues = append(fieldValues, destValue.FieldByName(field.GoName).Interface())
 	}
 	return fieldValues
 }
@@ -20,4 +20,116 @@ func TestParse(t *testing.T) {
 	if schema.GetField("Name").Tag != "PRIMARY KEY" {
 		t.Fatal("failed to parse primary key")
 	}
-}
\ No newline at end of file
+}
+
+func TestField_Constraints(t *testing.T) {
+	type Item struct {
+		Code  string `myorm:"NOT NULL UNIQUE DEFAULT 'it''s'"`
+		Count int    `myorm:"DEFAULT 0 CHECK (Count >= 0)"`
+		Note  string
+	}
+	schema := Parse(&Item{}, TestDial)
+	code, count, note := schema.GetField("Code"), schema.GetField("Count"), schema.GetField("Note")
+	if !code.IsNotNull() || !code.IsUnique() || *code.DefaultValue() != "'it''s'" {
+		t.Fatal("failed to parse constraints of Code", code.Tag)
+	}
+	if count.IsNotNull() || *count.DefaultValue() != "0" || note.DefaultValue() != nil {
+		t.Fatal("failed to parse default values")
+	}
+}
+
+func TestParse_Indexes(t *testing.T) {
+	type Member struct {
+		Name    string `myorm:"NOT NULL;index"`
+		Email   string `myorm:"uniqueIndex:uk_email"`
+		Team    string `myorm:"index:idx_team_role,priority:2"`
+		Role    string `myorm:"index:idx_team_role,priority:1"`
+		Deleted bool   `myorm:"index:idx_active,where:Deleted = 0 AND Role IN ('a', 'b')"`
+	}
+	schema := Parse(&Member{}, TestDial)
+	if schema.GetField("Name").Tag != "NOT NULL" || len(schema.Indexes) != 4 {
+		t.Fatal("failed to parse index tags", schema.Indexes)
+	}
+	name, email, teamRole, active := schema.Indexes[0], schema.Indexes[1], schema.Indexes[2], schema.Indexes[3]
+	if name.Name != "idx_Member_Name" || name.Unique || email.Name != "uk_email" || !email.Unique {
+		t.Fatal("failed to parse single column indexes", name, email)
+	}
+	if teamRole.Name != "idx_team_role" || teamRole.Columns[0] != "Role" || teamRole.Columns[1] != "Team" {
+		t.Fatal("failed to order composite index by priority", teamRole)
+	}
+	if active.Where != "Deleted = 0 AND Role IN ('a', 'b')" {
+		t.Fatal("failed to parse partial index", active.Where)
+	}
+}
+
+func TestParse_ForeignKeys(t *testing.T) {
+	type Line struct {
+		OrderID int `myorm:"NOT NULL;foreignKey:fk_line_order;references:Order(ID);constraint:OnDelete:cascade,OnUpdate:SET  NULL"`
+		OrderNo int `myorm:"foreignKey:fk_line_order;references:Order(No)"`
+		UserID  int `myorm:"references:User(ID)"`
+	}
+	schema := Parse(&Line{}, TestDial)
+	if len(schema.ForeignKeys) != 2 || schema.GetField("OrderID").Tag != "NOT NULL" {
+		t.Fatal("failed to parse foreign keys", schema.ForeignKeys)
+	}
+	order, user := schema.ForeignKeys[0], schema.ForeignKeys[1]
+	if order.Name != "fk_line_order" || len(order.Columns) != 2 || order.RefColumns[1] != "No" ||
+		order.OnDelete != "CASCADE" || order.OnUpdate != "SET NULL" {
+		t.Fatal("failed to parse composite foreign key", order)
+	}
+	if user.Name != "fk_Line_UserID" || user.RefTable != "User" || user.OnDelete != "" {
+		t.Fatal("failed to parse foreign key", user)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("invalid action should panic")
+		}
+	}()
+	type Bad struct {
+		UserID int `myorm:"references:User(ID);constraint:OnDelete:DROP"`
+	}
+	Parse(&Bad{}, TestDial)
+}
+
+func TestParse_VersionField(t *testing.T) {
+	type Doc struct {
+		ID  int    `myorm:"PRIMARY KEY"`
+		Rev uint32 `myorm:"version"`
+	}
+	if schema := Parse(&Doc{}, TestDial); schema.VersionField == nil || schema.VersionField.Name != "Rev" {
+		t.Fatal("failed to parse version field")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("non-integer version field should panic")
+		}
+	}()
+	type Bad struct {
+		ID  int    `myorm:"PRIMARY KEY"`
+		Rev string `myorm:"version"`
+	}
+	Parse(&Bad{}, TestDial)
+}
+
+type legacyUser struct {
+	ID    int64   `myorm:"column:user_id;PRIMARY KEY"`
+	Email *string `myorm:"column:e_mail;uniqueIndex"`
+	Age   *int
+}
+
+func (legacyUser) TableName() string { return "tbl_user" }
+
+func TestParse_Naming(t *testing.T) {
+	schema := Parse(&legacyUser{}, TestDial)
+	if schema.Name != "tbl_user" || schema.FieldNames[0] != "user_id" || schema.PrimaryField.GoName != "ID" {
+		t.Fatal("failed to parse table and column names", schema.Name, schema.FieldNames)
+	}
+	email, age := schema.GetField("e_mail"), schema.GetField("Age")
+	if email.Type != "text" || email.Tag != "" || age.Type != "integer" || schema.Indexes[0].Name != "idx_tbl_user_e_mail" {
+		t.Fatal("failed to parse pointer fields", email, age, schema.Indexes[0])
+	}
+	values := schema.RecordValues(&legacyUser{ID: 1})
+	if values[0] != int64(1) || values[1].(*string) != nil {
+		t.Fatal("failed to get record values by field name", values)
+	}
+}
@@ -1,7 +1,6 @@
 package session
 
 import (
-	"myorm/log"
 	"reflect"
 )
 
@@ -71,7 +70,7 @@ func (s *Session) CallMethod(method string, value interface{}) {
 	if fm.IsValid() {
 		if v := fm.Call(param); len(v) > 0 {
 			if err, ok := v[0].Interface().(error); ok {
-				log.Error(err)
+				s.Logger().Errorf(s.Context(), "%s: %v", method, err)
 			}
 		}
 	}
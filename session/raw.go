@@ -1,23 +1,30 @@
 package session
 
 import (
+	"context"
 	"database/sql"
 	"myorm/clause"
 	"myorm/dialect"
 	"myorm/log"
 	"myorm/schema"
 	"strings"
+	"time"
 )
 
 //当 tx 不为空时，则使用 tx 执行 SQL 语句，否则使用 db 执行 SQL 语句。
 type Session struct {
 	db *sql.DB //数据库引擎
+	ctx context.Context //上下文，为nil时使用context.Background()
 	dialectSQL dialect.Dialect //SQL软件的方言
+	conn     *sql.Conn //绑定的连接，不为空时所有语句都在这个连接上执行
 	tx       *sql.Tx //事务
+	txDepth  int //事务的嵌套层数，大于1时内层事务由保存点实现
 	refTable *schema.Schema //表框架
 	clause   clause.Clause //分句生成器
 	sql strings.Builder //SQL语句
 	sqlVars []interface{} //SQL语句的参数
+	logger   log.Logger //为nil时使用log.Default()
+	slowQuery *slowQuery //慢查询的阈值和回调，为nil时不检查
 }
 //会话里面只有表框架，并没有数据表。一个表框架对应一个数据表。
 //会话必须通过调用HasTable()才能知道数据库中有没有其表框架对应的数据表。
@@ -27,20 +34,58 @@ type CommonDB interface {
 	Query(query string, args ...interface{}) (*sql.Rows, error)
 	QueryRow(query string, args ...interface{}) *sql.Row
 	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
 }
 
 //检查一下sql.DB和sql.Tx有没有实现CommonDB
 var _ CommonDB = (*sql.DB)(nil)
 var _ CommonDB = (*sql.Tx)(nil)
 
-// 如果事务已经被定义，则执行事务。否则返回原数据库指针
+// 如果事务已经被定义，则执行事务。否则返回原数据库指针（绑定了连接时返回该连接）
 func (s *Session) DB() CommonDB {
 	if s.tx != nil {
 		return s.tx
 	}
+	if s.conn != nil {
+		return connDB{s.conn}
+	}
 	return s.db
 }
 
+//把会话绑定到连接池中的一个连接上，之后会话的所有语句（包括事务）都在这个连接上执行。
+//SQLite的PRAGMA foreign_keys等设置只对执行它的连接有效，需要在同一个连接上执行后续语句。
+//连接由调用者负责关闭。
+func (s *Session) WithConn(conn *sql.Conn) *Session {
+	s.conn = conn
+	return s
+}
+
+//*sql.Conn只有带上下文的方法，包装成CommonDB
+type connDB struct {
+	*sql.Conn
+}
+
+var _ CommonDB = connDB{}
+
+func (c connDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.QueryContext(context.Background(), query, args...)
+}
+
+func (c connDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.QueryRowContext(context.Background(), query, args...)
+}
+
+func (c connDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.ExecContext(context.Background(), query, args...)
+}
+
+//会话使用的方言
+func (s *Session) Dialect() dialect.Dialect {
+	return s.dialectSQL
+}
+
 func (s *Session) SQLDB() CommonDB {
 	return s.db
 }
@@ -49,6 +94,36 @@ func New(db0 *sql.DB,d0 dialect.Dialect) *Session {
 	return &Session{db: db0,dialectSQL:d0}
 }
 
+//设置会话的上下文，之后该会话执行的SQL语句、开启的事务都会使用这个上下文，
+//上下文被取消或超时后，正在执行的语句会被中断。钩子函数可以通过s.Context()获得它。
+//用法：s := engine.NewSession().WithContext(r.Context())
+func (s *Session) WithContext(ctx context.Context) *Session {
+	s.ctx = ctx
+	return s
+}
+
+//设置会话的Logger，会话执行的语句、事务等日志都写到l
+func (s *Session) WithLogger(l log.Logger) *Session {
+	s.logger = l
+	return s
+}
+
+//会话的Logger，没有设置时返回log.Default()
+func (s *Session) Logger() log.Logger {
+	if s.logger == nil {
+		return log.Default()
+	}
+	return s.logger
+}
+
+//获得会话的上下文，没有设置时返回context.Background()
+func (s *Session) Context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
 func (s *Session)Clear()  {
 	s.sql.Reset()
 	s.sqlVars=nil
@@ -63,36 +138,69 @@ func (s *Session)Raw(sql0 string,values ...interface{}) *Session {
 	return s
 }
 
+//会话中的SQL语句，占位符已按方言改写
+func (s *Session) query() string {
+	if d, ok := s.dialectSQL.(dialect.BindVarDialect); ok {
+		return d.Rebind(s.sql.String())
+	}
+	return s.sql.String()
+}
+
 //执行会话中的SQL语句及其参数
 func (s *Session)Exec() (sql.Result,error) {
 	defer s.Clear()
-	log.Info(s.sql.String(),s.sqlVars)
-	result,err:=s.DB().Exec(s.sql.String(),s.sqlVars...)
-	if err!=nil{
-		log.Error(err)
+	query:=s.query()
+	start := time.Now()
+	result,err:=s.DB().ExecContext(s.Context(),query,driverArgs(s.sqlVars)...)
+	rows := int64(-1)
+	if err == nil {
+		rows, _ = result.RowsAffected()
 	}
+	s.trace(s.Context(), query, s.sqlVars, start, rows, err)
 	return result,err
 }
 
 //返回多条记录
 func (s *Session)QueryRows() (*sql.Rows,error) {
 	defer s.Clear()
-	log.Info(s.sql.String(),s.sqlVars)
-	result,err:= s.DB().Query(s.sql.String(),s.sqlVars...)
-	if err!=nil{
-		log.Error(err)
-	}
+	query:=s.query()
+	start := time.Now()
+	result,err:= s.DB().QueryContext(s.Context(),query,driverArgs(s.sqlVars)...)
+	s.trace(s.Context(), query, s.sqlVars, start, -1, err)
 	return result,err
 }
 
 //返回1条记录
 func (s *Session)QueryRow() *sql.Row {
 	defer s.Clear()
-	log.Info(s.sql.String(),s.sqlVars)
-	result:=s.DB().QueryRow(s.sql.String(),s.sqlVars...)
+	query:=s.query()
+	start := time.Now()
+	result:=s.DB().QueryRowContext(s.Context(),query,driverArgs(s.sqlVars)...)
+	s.trace(s.Context(), query, s.sqlVars, start, -1, result.Err())
 	return result
 }
 
+//把执行完的语句交给Logger，rows为-1表示查询语句。参数中的敏感数据先被遮盖
+func (s *Session) trace(ctx context.Context, query string, args []interface{}, start time.Time, rows int64, err error) {
+	t := &log.Trace{SQL: query, Args: redactArgs(query, args), Rows: rows, Duration: time.Since(start), Err: err}
+	s.Logger().Trace(ctx, t)
+	if s.slowQuery != nil && t.Duration > s.slowQuery.threshold {
+		s.reportSlowQuery(ctx, t)
+	}
+}
+
+//传给方言的表结构查询方法，查询语句同样经过Logger
+type tracedQueryer struct {
+	s *Session
+}
+
+func (q tracedQueryer) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := q.s.DB().QueryContext(ctx, query, args...)
+	q.s.trace(ctx, query, args, start, -1, err)
+	return rows, err
+}
+
 //直接执行Clear()的函数：Exec()、QueryRows()、QueryRow()
 //间接执行Clear()的函数：Insert()、Find()、Update()、Delete()、Count()、First()
 //不会执行Clear()的函数：Limit()、Where()、OrderBy()
@@ -100,7 +208,12 @@ func (s *Session)QueryRow() *sql.Row {
 
 type TxFunc2 func(s *Session) (*Session, interface{}, error)
 func (s *Session) Transaction(f TxFunc2) (resultSession *Session, result interface{}, err error) {
-	if err := s.Begin(); err != nil { //开启一个事务。s.Begin()表示新建一个事务并将其指针保存到s中。
+	return s.TransactionWithOptions(nil, f)
+}
+
+//与Transaction()相同，但按照opts开启事务
+func (s *Session) TransactionWithOptions(opts *TxOptions, f TxFunc2) (resultSession *Session, result interface{}, err error) {
+	if err := s.BeginTx(opts); err != nil { //开启一个事务。s.Begin()表示新建一个事务并将其指针保存到s中。
 		return s,nil, err
 	}
 	//Recover 是一个Go语言的内建函数，可以让进入宕机流程中的 goroutine 恢复过来，
@@ -0,0 +1,56 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"myorm/log"
+	"reflect"
+	"testing"
+)
+
+func TestSession_WithContext(t *testing.T) {
+	s := accountSession(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.WithContext(ctx)
+	if _, err := s.Raw("DELETE FROM Account").Exec(); !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context.Canceled, got", err)
+	}
+	if err := s.Begin(); !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context.Canceled, got", err)
+	}
+	if n, _ := s.WithContext(context.Background()).Count(); n != 1 {
+		t.Fatal("canceled statement should not have run")
+	}
+}
+
+// traceLogger 记录每条语句的Trace
+type traceLogger struct {
+	log.Logger
+	traces []*log.Trace
+}
+
+func (l *traceLogger) Trace(ctx context.Context, t *log.Trace) {
+	l.traces = append(l.traces, t)
+}
+
+func TestSession_WithLogger(t *testing.T) {
+	s := accountSession(t)
+	l := &traceLogger{Logger: log.Default()}
+	s.WithLogger(l)
+	if _, err := s.Raw("UPDATE Account SET Name = ? WHERE ID = ?", "Sam", 1).Exec(); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = s.Raw("SELECT * FROM NoTable").QueryRows()
+	if len(l.traces) != 2 {
+		t.Fatal("expected 2 traces, got", len(l.traces))
+	}
+	exec, query := l.traces[0], l.traces[1]
+	if exec.SQL != "UPDATE Account SET Name = ? WHERE ID = ? " || !reflect.DeepEqual(exec.Args, []interface{}{"Sam", 1}) ||
+		exec.Rows != 1 || exec.Duration <= 0 || exec.Err != nil {
+		t.Fatalf("unexpected trace of Exec: %+v", exec)
+	}
+	if query.Rows != -1 || query.Err == nil {
+		t.Fatalf("unexpected trace of failed query: %+v", query)
+	}
+}
@@ -2,25 +2,56 @@ package session
 
 import (
 	"errors"
+	"fmt"
 	"myorm/clause"
+	"myorm/schema"
 	"reflect"
+	"strings"
 )
 
 
+var (
+	//乐观锁检查失败：记录已被其他人修改或删除
+	ErrStaleObject = errors.New("stale object: record was modified or deleted")
+	//Save等需要主键的操作，模型却没有主键
+	ErrNoPrimaryKey = errors.New("model has no primary key")
+	//Save带版本号字段的模型时传入的不是指针，无法把新的版本号写回实例
+	ErrNotPointer = errors.New("model with a version field must be saved through a pointer")
+)
+
 //直接执行Clear()的函数：Exec()、QueryRows()、QueryRow()
-//间接执行Clear()的函数：Insert()、Find()、Update()、Delete()、Count()、First()
+//间接执行Clear()的函数：Insert()、Find()、Update()、Delete()、Count()、First()、Save()
 //不会执行Clear()的函数：Limit()、Where()、OrderBy()
 //执行Clear()后，会话的SQL语句及其参数都会被清空。
 //链式操作时，须注意使不会执行Clear()的函数在前面，其他在后面。
 
-//传入多个结构体实例，把每个实例的值改成一条记录并插入数据表中
+//传入多个结构体实例，把每个实例的值改成一条记录并插入数据表中。
+//值为零的自增字段（注解含autoIncrement）不插入，由数据库生成；一次插入的多条记录中，
+//自增字段须都为零或都不为零
 func (s *Session) Insert(values ...interface{}) (int64, error) {
 	recordValues := make([]interface{}, 0)
-	for _, value := range values {
+	var columns []string
+	for i, value := range values {
 		s.CallMethod(BeforeInsert, value)
 		table := s.Model(value).RefTable()
-		s.clause.Set(clause.INSERT, table.Name, table.FieldNames)
-		recordValues = append(recordValues, table.RecordValues(value))
+		dest := reflect.Indirect(reflect.ValueOf(value))
+		var cols []string
+		var record []interface{}
+		for j, v := range table.RecordValues(value) {
+			field := table.Fields[j]
+			if field.AutoIncrement && dest.FieldByName(field.GoName).IsZero() {
+				continue
+			}
+			cols = append(cols, field.Name)
+			record = append(record, fieldValue(field, v))
+		}
+		if i > 0 && strings.Join(cols, ",") != strings.Join(columns, ",") {
+			s.Clear()
+			return 0, fmt.Errorf("insert into %s: auto-increment fields must be zero in all records or in none", table.Name)
+		}
+		columns = cols
+		s.clause.Set(clause.INSERT, s.quote(table.Name), s.quoteAll(cols))
+		recordValues = append(recordValues, record)
 	}
 	//recordValues类似于 [[9 "amy"] [92 "john"]]
 
@@ -43,7 +74,7 @@ func (s *Session) Find(values interface{}) error {
 	destType := destSlice.Type().Elem()
 	table := s.Model(reflect.New(destType).Elem().Interface()).RefTable()
 
-	s.clause.Set(clause.SELECT, table.Name, table.FieldNames)
+	s.clause.Set(clause.SELECT, s.quote(table.Name), s.quoteAll(table.FieldNames))
 	sql, vars := s.clause.Build(clause.SELECT, clause.WHERE, clause.ORDERBY, clause.LIMIT)
 	//rows, err := s.Raw(sql, vars...).QueryRows()
 	s0:=s.Raw(sql, vars...)
@@ -59,8 +90,8 @@ func (s *Session) Find(values interface{}) error {
 
 		var value []interface{}
 		//fmt.Println("dest",dest,values)
-		for _, name := range table.FieldNames {
-			value = append(value, dest.FieldByName(name).Addr().Interface())
+		for _, field := range table.Fields {
+			value = append(value, dest.FieldByName(field.GoName).Addr().Interface())
 		}
 		if err := rows.Scan(value...); err != nil {
 			return err
@@ -89,19 +120,120 @@ func (s *Session) Update(kv ...interface{}) (int64, error) {
 			m[kv[i].(string)] = kv[i+1]
 		}
 	}
-	s.clause.Set(clause.UPDATE, s.RefTable().Name, m)
+	table := s.RefTable()
+	checked := false
+	if vf := table.VersionField; vf != nil {
+		//带版本号字段的表：kv中给出的版本号作为期望值放进WHERE，SET中版本号自增
+		if expected, ok := m[vf.Name]; ok {
+			m = copyWithout(m, vf.Name)
+			s.clause.AndWhere(s.quote(vf.Name)+" = ?", expected)
+			checked = true
+		}
+	}
+	s.setUpdate(table, m)
 	sql, vars := s.clause.Build(clause.UPDATE, clause.WHERE)
 	result, err := s.Raw(sql, vars...).Exec()
 	if err != nil {
 		return 0, err
 	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if checked && affected == 0 {
+		return 0, ErrStaleObject
+	}
 	s.CallMethod(AfterUpdate, nil)
-	return result.RowsAffected()
+	return affected, nil
+}
+
+//把一个结构体实例按主键整体写回数据表。
+//如果模型有版本号字段（注解含version），WHERE中会带上当前版本号，
+//更新成功后实例的版本号加一，所以须传入指针，否则返回ErrNotPointer；没有记录被更新时返回ErrStaleObject。
+//用法： u := &User{}; _ = s.First(u); u.Age = 20; _, err := s.Save(u)
+func (s *Session) Save(value interface{}) (int64, error) {
+	s.CallMethod(BeforeUpdate, value)
+	table := s.Model(value).RefTable()
+	pk, vf := table.PrimaryField, table.VersionField
+	if pk == nil {
+		return 0, ErrNoPrimaryKey
+	}
+	dest := reflect.Indirect(reflect.ValueOf(value))
+	if vf != nil && !dest.CanAddr() {
+		return 0, ErrNotPointer
+	}
+	m := make(map[string]interface{})
+	for _, field := range table.Fields {
+		if field.IsPrimaryKey() || field == vf {
+			continue
+		}
+		m[field.Name] = dest.FieldByName(field.GoName).Interface()
+	}
+	//联合主键的每一列都要出现在WHERE中
+	s.clause.Set(clause.WHERE, s.quote(pk.Name)+" = ?", dest.FieldByName(pk.GoName).Interface())
+	for _, f := range table.PrimaryKeys[1:] {
+		s.clause.AndWhere(s.quote(f.Name)+" = ?", dest.FieldByName(f.GoName).Interface())
+	}
+	if vf != nil {
+		s.clause.AndWhere(s.quote(vf.Name)+" = ?", dest.FieldByName(vf.GoName).Interface())
+	}
+	s.setUpdate(table, m)
+	sql, vars := s.clause.Build(clause.UPDATE, clause.WHERE)
+	result, err := s.Raw(sql, vars...).Exec()
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if vf != nil {
+		if affected == 0 {
+			return 0, ErrStaleObject
+		}
+		incVersion(dest.FieldByName(vf.GoName))
+	}
+	s.CallMethod(AfterUpdate, value)
+	return affected, nil
+}
+
+//设置UPDATE分句，列名加上引号；有版本号字段时版本号自增
+func (s *Session) setUpdate(table *schema.Schema, m map[string]interface{}) {
+	quoted := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		quoted[s.quote(k)] = fieldValue(table.GetField(k), v)
+	}
+	if vf := table.VersionField; vf != nil {
+		s.clause.Set(clause.UPDATE, s.quote(table.Name), quoted, s.quote(vf.Name))
+		return
+	}
+	s.clause.Set(clause.UPDATE, s.quote(table.Name), quoted)
+}
+
+//版本号加一，支持有符号和无符号整数
+func incVersion(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(v.Int() + 1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(v.Uint() + 1)
+	}
+}
+
+//复制一个map并去掉其中的一个键，避免修改调用者传入的map
+func copyWithout(m map[string]interface{}, key string) map[string]interface{} {
+	result := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if k != key {
+			result[k] = v
+		}
+	}
+	return result
 }
 
 func (s *Session) Delete() (int64, error) {
 	s.CallMethod(BeforeDelete, nil)
-	s.clause.Set(clause.DELETE, s.RefTable().Name)
+	s.clause.Set(clause.DELETE, s.quote(s.RefTable().Name))
 	sql, vars := s.clause.Build(clause.DELETE, clause.WHERE)
 	result, err := s.Raw(sql, vars...).Exec()
 	if err != nil {
@@ -114,7 +246,7 @@ func (s *Session) Delete() (int64, error) {
 
 // Count records with where clause
 func (s *Session) Count() (int64, error) {
-	s.clause.Set(clause.COUNT, s.RefTable().Name)
+	s.clause.Set(clause.COUNT, s.quote(s.RefTable().Name))
 	sql, vars := s.clause.Build(clause.COUNT, clause.WHERE)
 	row := s.Raw(sql, vars...).QueryRow()
 	var tmp int64
@@ -145,6 +277,17 @@ func (s *Session) OrderBy(desc string) *Session {
 	return s
 }
 
+//按一列排序，列名会加上引号，适合列名来自用户输入（如接口的排序参数）的场合。
+//用法：s.OrderByColumn(r.URL.Query().Get("sort"), true).Find(&users)
+func (s *Session) OrderByColumn(column string, desc bool) *Session {
+	order := s.quote(column) + " ASC"
+	if desc {
+		order = s.quote(column) + " DESC"
+	}
+	s.clause.Set(clause.ORDERBY, order)
+	return s
+}
+
 //获得第一个记录
 //用法： u := &User{}
 //_ = s.OrderBy("Age DESC").First(u)
@@ -1,2 +1,205 @@
 package session
 
+import (
+	"database/sql"
+	"errors"
+	"myorm/dialect"
+	"reflect"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type Account struct {
+	ID      int `myorm:"PRIMARY KEY"`
+	Name    string
+	Version int `myorm:"version"`
+}
+
+//每个测试使用一个独立的内存数据库
+func NewSession(t *testing.T) *Session {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	dial, _ := dialect.GetDialect("sqlite3")
+	return New(db, dial)
+}
+
+func accountSession(t *testing.T) *Session {
+	s := NewSession(t).Model(&Account{})
+	if err := s.CreateTable(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Insert(&Account{ID: 1, Name: "Tom"}); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSession_Save(t *testing.T) {
+	s := accountSession(t)
+	a, b := &Account{}, &Account{}
+	_ = s.Where("ID = ?", 1).First(a)
+	_ = s.Where("ID = ?", 1).First(b)
+
+	a.Name = "Sam"
+	if _, err := s.Save(a); err != nil || a.Version != 1 {
+		t.Fatal("failed to save account", err)
+	}
+	b.Name = "Amy"
+	if _, err := s.Save(b); !errors.Is(err, ErrStaleObject) {
+		t.Fatal("expected ErrStaleObject, got", err)
+	}
+	u := &Account{}
+	_ = s.Where("ID = ?", 1).First(u)
+	if u.Name != "Sam" || u.Version != 1 {
+		t.Fatal("stale save overwrote the record", u)
+	}
+	//传入结构体而不是指针时无法写回版本号，语句不执行
+	if _, err := s.Save(Account{ID: 1, Name: "Amy", Version: 1}); !errors.Is(err, ErrNotPointer) {
+		t.Fatal("expected ErrNotPointer, got", err)
+	}
+	_ = s.Where("ID = ?", 1).First(u)
+	if u.Name != "Sam" || u.Version != 1 {
+		t.Fatal("save by value should not update the record", u)
+	}
+}
+
+func TestSession_UpdateVersion(t *testing.T) {
+	s := accountSession(t)
+	if _, err := s.Where("ID = ?", 1).Update("Name", "Sam", "Version", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Where("ID = ?", 1).Update("Name", "Amy", "Version", 0); !errors.Is(err, ErrStaleObject) {
+		t.Fatal("expected ErrStaleObject, got", err)
+	}
+	//不带版本号的更新不做检查，但版本号仍然自增
+	if _, err := s.Where("ID = ?", 1).Update("Name", "Amy"); err != nil {
+		t.Fatal(err)
+	}
+	u := &Account{}
+	_ = s.First(u)
+	if u.Name != "Amy" || u.Version != 2 {
+		t.Fatal("failed to update with version", u)
+	}
+}
+
+//表名和列名都是SQL关键字
+type Order struct {
+	Group string `myorm:"PRIMARY KEY"`
+	Limit int
+}
+
+func TestSession_QuoteIdentifiers(t *testing.T) {
+	s := NewSession(t).Model(&Order{})
+	if err := s.CreateTable(); err != nil || !s.HasTable() {
+		t.Fatal("failed to create table Order", err)
+	}
+	if _, err := s.Insert(&Order{"a", 2}, &Order{"b", 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Where(`"Group" = ?`, "a").Update("Limit", 3); err != nil {
+		t.Fatal(err)
+	}
+	var orders []Order
+	if err := s.OrderByColumn("Limit", true).Find(&orders); err != nil || orders[0].Limit != 3 {
+		t.Fatal("failed to query table Order", orders, err)
+	}
+	//来自用户输入的列名只会被当作一个标识符，不能被拼接成SQL
+	_ = s.OrderByColumn(`Limit"; DROP TABLE "Order`, false).Find(&orders)
+	if !s.HasTable() {
+		t.Fatal("table Order should not be dropped")
+	}
+}
+
+type Ticket struct {
+	ID    int64 `myorm:"PRIMARY KEY;autoIncrement"`
+	Title string
+}
+
+func TestSession_AutoIncrement(t *testing.T) {
+	s := NewSession(t).Model(&Ticket{})
+	if err := s.CreateTable(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Raw(`INSERT INTO "Ticket" ("Title") VALUES (?)`, "a").Exec(); err != nil {
+		t.Fatal(err)
+	}
+	//值为零的自增主键不插入，由数据库生成
+	if _, err := s.Insert(&Ticket{Title: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Insert(&Ticket{Title: "c"}, &Ticket{Title: "d"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Insert(&Ticket{ID: 10, Title: "e"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Insert(&Ticket{ID: 11, Title: "f"}, &Ticket{Title: "g"}); err == nil {
+		t.Fatal("mixed zero and non-zero IDs should fail")
+	}
+	var tickets []Ticket
+	if err := s.OrderBy("ID").Find(&tickets); err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	for _, ticket := range tickets {
+		ids = append(ids, ticket.ID)
+	}
+	if !reflect.DeepEqual(ids, []int64{1, 2, 3, 4, 10}) {
+		t.Fatal("failed to generate IDs", tickets)
+	}
+}
+
+//表名、列名与结构体不同的模型
+type Member struct {
+	ID       int     `myorm:"column:member_id;PRIMARY KEY"`
+	NickName *string `myorm:"column:nick_name"`
+	Version  int     `myorm:"column:ver;version"`
+}
+
+func (Member) TableName() string { return "member" }
+
+func TestSession_ColumnNames(t *testing.T) {
+	s := NewSession(t).Model(&Member{})
+	if err := s.CreateTable(); err != nil {
+		t.Fatal(err)
+	}
+	if tables, _ := s.Tables(); !reflect.DeepEqual(tables, []string{"member"}) {
+		t.Fatal("TableName() should name the table", tables)
+	}
+	tom := "Tom"
+	if _, err := s.Insert(&Member{ID: 1, NickName: &tom}, &Member{ID: 2}); err != nil {
+		t.Fatal(err)
+	}
+	//NULL读成nil指针
+	var members []Member
+	if err := s.OrderBy("member_id").Find(&members); err != nil || len(members) != 2 ||
+		*members[0].NickName != "Tom" || members[1].NickName != nil {
+		t.Fatal("failed to find members", members, err)
+	}
+	//Update的键是列名
+	if _, err := s.Where("member_id = ?", 2).Update("nick_name", "Sam"); err != nil {
+		t.Fatal(err)
+	}
+	m := &Member{}
+	if err := s.Where("member_id = ?", 2).First(m); err != nil || m.NickName == nil || *m.NickName != "Sam" || m.Version != 1 {
+		t.Fatal("failed to update by column name", m, err)
+	}
+	m.NickName = nil
+	if n, err := s.Save(m); err != nil || n != 1 || m.Version != 2 {
+		t.Fatal("failed to save member", n, err)
+	}
+	if err := s.Where("member_id = ?", 2).First(m); err != nil || m.NickName != nil {
+		t.Fatal("failed to save NULL", m, err)
+	}
+	if n, err := s.Where("nick_name IS NULL").Delete(); err != nil || n != 1 {
+		t.Fatal("failed to delete member", n, err)
+	}
+	if n, err := s.Count(); err != nil || n != 1 {
+		t.Fatal("expected one member left", n, err)
+	}
+}
@@ -0,0 +1,58 @@
+package session
+
+import (
+	"myorm/log"
+	"myorm/schema"
+)
+
+// sensitive 敏感的参数，执行时使用原值，日志中显示为log.Masked
+type sensitive struct {
+	value interface{}
+}
+
+//包装一个敏感的参数，使它不出现在日志中。模型中注解含sensitive的字段由Insert、Update、Save自动包装，
+//其他语句的参数需要手动包装，如：s.Where("Token = ?", session.Sensitive(token)).First(&u)
+func Sensitive(value interface{}) interface{} {
+	if _, ok := value.(sensitive); ok {
+		return value
+	}
+	return sensitive{value}
+}
+
+//字段的值，敏感字段的值被包装
+func fieldValue(field *schema.Field, value interface{}) interface{} {
+	if field != nil && field.Sensitive {
+		return Sensitive(value)
+	}
+	return value
+}
+
+//传给数据库驱动的参数：去掉包装
+func driverArgs(args []interface{}) []interface{} {
+	var result []interface{}
+	for i, arg := range args {
+		if v, ok := arg.(sensitive); ok {
+			if result == nil {
+				result = append([]interface{}(nil), args...)
+			}
+			result[i] = v.value
+		}
+	}
+	if result == nil {
+		return args
+	}
+	return result
+}
+
+//写到日志的参数：敏感的参数换成log.Masked，再按log的全局设置处理
+func redactArgs(query string, args []interface{}) []interface{} {
+	logged := make([]interface{}, len(args))
+	for i, arg := range args {
+		if _, ok := arg.(sensitive); ok {
+			logged[i] = log.Masked
+		} else {
+			logged[i] = arg
+		}
+	}
+	return log.Redact(query, logged)
+}
@@ -0,0 +1,77 @@
+package session
+
+import (
+	"fmt"
+	"myorm/log"
+	"strings"
+	"testing"
+)
+
+type Credential struct {
+	ID    int `myorm:"PRIMARY KEY"`
+	User  string
+	Token string `myorm:"NOT NULL;sensitive"`
+}
+
+//日志中所有参数的文本
+func loggedArgs(traces []*log.Trace) string {
+	var args []string
+	for _, t := range traces {
+		args = append(args, fmt.Sprint(t.Args))
+	}
+	return strings.Join(args, " ")
+}
+
+func TestSession_Sensitive(t *testing.T) {
+	l := &traceLogger{Logger: log.Default()}
+	s := NewSession(t).WithLogger(l).Model(&Credential{})
+	if err := s.CreateTable(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Insert(&Credential{ID: 1, User: "tom", Token: "secret-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Where("ID = ?", 1).Update("Token", "secret-2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Save(&Credential{ID: 1, User: "tom", Token: "secret-3"}); err != nil {
+		t.Fatal(err)
+	}
+	c := &Credential{}
+	if err := s.Where("Token = ?", Sensitive("secret-3")).First(c); err != nil || c.Token != "secret-3" {
+		t.Fatal("failed to query by sensitive value", c, err)
+	}
+	logged := loggedArgs(l.traces)
+	if strings.Contains(logged, "secret") {
+		t.Fatal("sensitive values in logs:", logged)
+	}
+	if strings.Count(logged, log.Masked) != 4 || !strings.Contains(logged, "tom") {
+		t.Fatal("unexpected logged args:", logged)
+	}
+}
+
+func TestSession_Redact(t *testing.T) {
+	l := &traceLogger{Logger: log.Default()}
+	s := accountSession(t).WithLogger(l)
+	log.SetRedactor(func(sql string, args []interface{}) []interface{} {
+		for i, arg := range args {
+			if _, ok := arg.(string); ok {
+				args[i] = "?"
+			}
+		}
+		return args
+	})
+	defer log.SetRedactor(nil)
+	args := []interface{}{"Sam", 1}
+	if _, err := s.Raw("UPDATE Account SET Name = ? WHERE ID = ?", args...).Exec(); err != nil {
+		t.Fatal(err)
+	}
+	log.SetPlaceholdersOnly(true)
+	defer log.SetPlaceholdersOnly(false)
+	if _, err := s.Where("Name = ?", "Sam").Count(); err != nil {
+		t.Fatal(err)
+	}
+	if logged := loggedArgs(l.traces); logged != "[? 1] []" || args[0] != "Sam" {
+		t.Fatal("unexpected logged args:", logged, args)
+	}
+}
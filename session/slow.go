@@ -0,0 +1,66 @@
+package session
+
+import (
+	"context"
+	"myorm/log"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//执行时间超过阈值的语句称为慢查询，写到会话的Logger（log.LogSlowQuery），并交给回调函数。
+//慢查询记录了执行语句的用户代码位置，即调用栈中第一个不属于myorm的函数（测试文件除外）
+
+// SlowQueryFunc 慢查询的回调函数，q.Args与日志中的参数相同
+type SlowQueryFunc func(ctx context.Context, q *log.SlowQuery)
+
+type slowQuery struct {
+	threshold time.Duration
+	callback  SlowQueryFunc
+}
+
+//设置慢查询的阈值，执行时间超过threshold的语句会写到日志并调用callback（可以为nil）。
+//threshold不大于0时不检查
+func (s *Session) WithSlowQuery(threshold time.Duration, callback SlowQueryFunc) *Session {
+	s.slowQuery = nil
+	if threshold > 0 {
+		s.slowQuery = &slowQuery{threshold: threshold, callback: callback}
+	}
+	return s
+}
+
+func (s *Session) reportSlowQuery(ctx context.Context, t *log.Trace) {
+	q := &log.SlowQuery{Trace: t, Threshold: s.slowQuery.threshold, Caller: caller()}
+	log.LogSlowQuery(ctx, s.Logger(), q)
+	if s.slowQuery.callback != nil {
+		s.slowQuery.callback(ctx, q)
+	}
+}
+
+//myorm的模块路径，如"myorm"
+var module = strings.TrimSuffix(reflect.TypeOf(Session{}).PkgPath(), "/session")
+
+//调用栈中第一个myorm以外（或测试文件中）的函数的位置，格式为file:line
+func caller() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !isInternal(frame) {
+			return frame.File + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+//函数属于myorm，测试文件中的函数不算
+func isInternal(frame runtime.Frame) bool {
+	if strings.HasSuffix(frame.File, "_test.go") {
+		return false
+	}
+	return strings.HasPrefix(frame.Function, module+".") || strings.HasPrefix(frame.Function, module+"/")
+}
@@ -0,0 +1,51 @@
+package session
+
+import (
+	"context"
+	"myorm/log"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// slowLogger 记录慢查询
+type slowLogger struct {
+	traceLogger
+	slow []*log.SlowQuery
+}
+
+func (l *slowLogger) SlowQuery(ctx context.Context, q *log.SlowQuery) {
+	l.slow = append(l.slow, q)
+}
+
+func TestSession_WithSlowQuery(t *testing.T) {
+	s := accountSession(t)
+	l := &slowLogger{traceLogger: traceLogger{Logger: log.Default()}}
+	var reported []*log.SlowQuery
+	s.WithLogger(l).WithSlowQuery(time.Hour, func(ctx context.Context, q *log.SlowQuery) {
+		reported = append(reported, q)
+	})
+	if rows, err := s.Raw("SELECT * FROM Account").QueryRows(); err == nil {
+		_ = rows.Close()
+	}
+	if len(l.slow) != 0 || len(reported) != 0 {
+		t.Fatal("statement faster than threshold should not be reported")
+	}
+
+	s.WithSlowQuery(time.Nanosecond, func(ctx context.Context, q *log.SlowQuery) {
+		reported = append(reported, q)
+	})
+	_, file, line, _ := runtime.Caller(0)
+	_ = s.Where("ID = ?", 1).First(&Account{})
+	if len(l.slow) != 1 || len(reported) != 1 || l.slow[0] != reported[0] {
+		t.Fatal("expected 1 slow query reported to logger and callback")
+	}
+	q := reported[0]
+	if q.Caller != file+":"+strconv.Itoa(line+1) {
+		t.Fatalf("expected caller %s:%d, got %s", file, line+1, q.Caller)
+	}
+	if q.Threshold != time.Nanosecond || q.Duration <= q.Threshold || len(q.Args) != 2 || q.Args[0] != 1 {
+		t.Fatalf("unexpected slow query: %+v", q)
+	}
+}
@@ -2,9 +2,10 @@ package session
 
 import (
 	"fmt"
-	"myorm/log"
+	"myorm/dialect"
 	"myorm/schema"
 	"reflect"
+	"regexp"
 	"strings"
 )
 
@@ -24,24 +25,138 @@ func (s *Session) Model(value interface{}) *Session {
 //获得会话对应的表框架
 func (s *Session) RefTable() *schema.Schema {
 	if s.refTable == nil {
-		log.Error("Model is not set")
+		s.Logger().Errorf(s.Context(), "Model is not set")
 	}
 	return s.refTable
 }
 
+//建表，并建立注解中声明的索引
 func (s *Session)CreateTable() error {
-	table:=s.RefTable()
+	stmts, err := s.CreateIndexesSQL()
+	if err != nil {
+		return err
+	}
+	stmts = append([]string{s.CreateTableSQL(s.RefTable().Name)}, stmts...)
+	for _, stmt := range stmts {
+		if _, err = s.Raw(stmt).Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//根据会话的表框架生成建表语句，表名为tableName（重建表时需要先用一个临时的表名建表）
+func (s *Session) CreateTableSQL(tableName string) string {
+	return s.CreateTableSQLWithout(tableName, nil)
+}
+
+//与CreateTableSQL()相同，但不包括skip中的外键（互相引用的表须在都建立之后再添加外键），
+//constraints是追加在最后的表约束（如重建表时保留的外键）
+func (s *Session) CreateTableSQLWithout(tableName string, skip []*schema.ForeignKey, constraints ...string) string {
 	col:=make([]string,0)
-	for _,value:=range table.Fields{
-		//col=append(col,value.Name+" "+value.Type+" "+value.Tag)
-		col = append(col, fmt.Sprintf("%s %s %s", value.Name, value.Type, value.Tag))
+	for _,value:=range s.RefTable().Fields{
+		col = append(col, s.ColumnDefinition(value))
 	}
+	if pks := s.RefTable().PrimaryKeys; len(pks) > 1 {
+		names := make([]string, len(pks))
+		for i, f := range pks {
+			names[i] = f.Name
+		}
+		col = append(col, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(s.quoteAll(names), ", ")))
+	}
+	for _, fk := range s.RefTable().ForeignKeys {
+		skipped := false
+		for _, k := range skip {
+			skipped = skipped || k == fk
+		}
+		if !skipped {
+			col = append(col, s.ForeignKeyDefinition(fk))
+		}
+	}
+	col = append(col, constraints...)
 	s1:=strings.Join(col,",")
-	s2:=fmt.Sprintf("CREATE TABLE %s (%s);",table.Name,s1)
-	_,err:=s.Raw(s2).Exec()
-	return err
+	return fmt.Sprintf("CREATE TABLE %s (%s);",s.quote(tableName),s1)
+}
+
+//建表语句中的外键约束：CONSTRAINT 名字 FOREIGN KEY (列) REFERENCES 表 (列) [ON DELETE 动作] [ON UPDATE 动作]
+func (s *Session) ForeignKeyDefinition(fk *schema.ForeignKey) string {
+	def := fmt.Sprintf("CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)", s.quote(fk.Name),
+		strings.Join(s.quoteAll(fk.Columns), ", "), s.quote(fk.RefTable), strings.Join(s.quoteAll(fk.RefColumns), ", "))
+	if fk.OnDelete != "" {
+		def += " ON DELETE " + fk.OnDelete
+	}
+	if fk.OnUpdate != "" {
+		def += " ON UPDATE " + fk.OnUpdate
+	}
+	return def
+}
+
+//注解中声明的全部索引的建立语句
+func (s *Session) CreateIndexesSQL() ([]string, error) {
+	var stmts []string
+	for _, idx := range s.RefTable().Indexes {
+		stmt, err := s.CreateIndexSQL(idx)
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+	}
+	return stmts, nil
+}
+
+//在会话的表上建立索引的语句，数据库不支持部分索引时返回ErrNotSupported
+func (s *Session) CreateIndexSQL(idx *schema.Index) (string, error) {
+	unique, where := "", ""
+	if idx.Unique {
+		unique = "UNIQUE "
+	}
+	if idx.Where != "" {
+		if !s.dialectSQL.Capabilities().PartialIndex {
+			return "", fmt.Errorf("%w: partial index %s", dialect.ErrNotSupported, idx.Name)
+		}
+		where = " WHERE " + idx.Where
+	}
+	return fmt.Sprintf("CREATE %sINDEX %s ON %s (%s)%s;", unique, s.quote(idx.Name), s.quote(s.RefTable().Name),
+		strings.Join(s.quoteAll(idx.Columns), ", "), where), nil
+}
+
+//删除会话的表上的索引的语句
+func (s *Session) DropIndexSQL(index string) string {
+	if d, ok := s.dialectSQL.(dialect.IndexDropper); ok {
+		return d.DropIndexSQL(s.RefTable().Name, index)
+	}
+	return fmt.Sprintf("DROP INDEX %s;", s.quote(index))
 }
 
+//按方言给标识符加上引号
+func (s *Session) quote(name string) string {
+	return s.dialectSQL.Quote(name)
+}
+
+func (s *Session) quoteAll(names []string) []string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = s.quote(name)
+	}
+	return quoted
+}
+
+//建表语句中的一列：列名 类型 约束 [自增关键字]
+func (s *Session) ColumnDefinition(f *schema.Field) string {
+	tag := f.Tag
+	if s.refTable != nil && len(s.refTable.PrimaryKeys) > 1 {
+		//联合主键写在表约束中，列上不能再有PRIMARY KEY
+		tag = primaryKeyRegexp.ReplaceAllString(tag, "")
+	}
+	if !f.AutoIncrement {
+		return fmt.Sprintf("%s %s %s", s.quote(f.Name), f.Type, tag)
+	}
+	typ, keyword := s.dialectSQL.AutoIncrement(f.Type)
+	return strings.TrimSpace(fmt.Sprintf("%s %s %s %s", s.quote(f.Name), typ, tag, keyword))
+}
+
+var primaryKeyRegexp = regexp.MustCompile(`(?i)\bPRIMARY\s+KEY\b`)
+
 //HasTable()是根据结构体的名称（string）来判断的
 func (s *Session)HasTable() bool {
 	d0:=s.dialectSQL
@@ -51,8 +166,62 @@ func (s *Session)HasTable() bool {
 	_ = result.Scan(&name1)
 	return  s.RefTable().Name==name1
 }
+//数据库中的全部表名
+func (s *Session) Tables() ([]string, error) {
+	return s.dialectSQL.Tables(s.Context(), tracedQueryer{s})
+}
+
+//数据库中建立表及其索引、触发器的语句，方言没有实现SchemaDumper时返回ErrNotSupported
+func (s *Session) DumpTable(table string) ([]string, error) {
+	d, ok := s.dialectSQL.(dialect.SchemaDumper)
+	if !ok {
+		return nil, fmt.Errorf("%w: dump table %s", dialect.ErrNotSupported, table)
+	}
+	return d.DumpTable(s.Context(), tracedQueryer{s}, table)
+}
+
+//读取数据库中一个表的结构：列（类型、是否可空、默认值、主键）、索引和外键。
+//会话处于事务中时在事务内读取，可以看到事务中尚未提交的修改。
+func (s *Session) Inspect(table string) (*dialect.TableInfo, error) {
+	var err error
+	ctx, db := s.Context(), tracedQueryer{s}
+	info := &dialect.TableInfo{Name: table}
+	if info.Columns, err = s.dialectSQL.Columns(ctx, db, table); err != nil {
+		return nil, err
+	}
+	if len(info.Columns) == 0 {
+		return nil, fmt.Errorf("table %s doesn't exist", table)
+	}
+	if info.Indexes, err = s.dialectSQL.Indexes(ctx, db, table); err != nil {
+		return nil, err
+	}
+	if info.ForeignKeys, err = s.dialectSQL.ForeignKeys(ctx, db, table); err != nil {
+		return nil, err
+	}
+	//SQLite的外键省略被引用的列时引用的是主键，查出主键的列名，迁移时才能与注解比较
+	for i, fk := range info.ForeignKeys {
+		if len(fk.RefColumns) == 0 || fk.RefColumns[0] != "" {
+			continue
+		}
+		refCols, err := s.dialectSQL.Columns(ctx, db, fk.RefTable)
+		if err != nil {
+			return nil, err
+		}
+		var pk []string
+		for _, col := range refCols {
+			if col.PrimaryKey {
+				pk = append(pk, col.Name)
+			}
+		}
+		if len(pk) == len(fk.RefColumns) {
+			info.ForeignKeys[i].RefColumns = pk
+		}
+	}
+	return info, nil
+}
+
 func (s *Session) DropTable() error {
-	_, err := s.Raw(fmt.Sprintf("DROP TABLE IF EXISTS %s", s.RefTable().Name)).Exec()
+	_, err := s.Raw(fmt.Sprintf("DROP TABLE IF EXISTS %s", s.quote(s.RefTable().Name))).Exec()
 	return err
 }
 /*
@@ -1,5 +1,13 @@
 package session
-import "myorm/log"
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"myorm/dialect"
+)
+
+//没有开启事务就调用Commit()或Rollback()
+var ErrNoTransaction = errors.New("no transaction in progress")
 
 //数据库事务(transaction)是访问并可能操作各种数据项的一个数据库操作序列，
 //这些操作要么全部执行,要么全部不执行，是一个不可分割的工作单位。
@@ -8,34 +16,164 @@ import "myorm/log"
 //事务开始之后不断进行读写操作，但写操作仅仅将数据写入磁盘缓冲区，而非真正写入磁盘内。
 //顺利完成所有操作则提交，数据保存到磁盘；否则回滚。
 //本框架中事务的实现有两种，分别是Session的method和Engine的的method。
-//调用 s.db.Begin() 得到 *sql.Tx 对象，赋值给 s.tx。
+//调用 s.db.BeginTx() 得到 *sql.Tx 对象，赋值给 s.tx。
+//事务使用会话的上下文，上下文被取消时事务会被自动回滚。
 func (s *Session) Begin() (err error) {
-	log.Info("transaction begin")
-	if s.tx, err = s.db.Begin(); err != nil {
-		log.Error(err)
+	return s.BeginTx(nil)
+}
+
+// TxOptions 事务选项
+type TxOptions struct {
+	Isolation sql.IsolationLevel //隔离级别，默认为数据库的默认级别
+	ReadOnly  bool               //只读事务
+	//数据库自己的事务模式。SQLite可以是"DEFERRED"（默认）、"IMMEDIATE"或"EXCLUSIVE"：
+	//DEFERRED事务第一次写入时才申请写锁，多个写事务同时从读升级为写时会出现"database is locked"，
+	//IMMEDIATE/EXCLUSIVE在事务开始时就申请锁，拿不到锁时在开始处等待或失败。
+	Mode string
+}
+
+//按照指定的选项开启一个事务，opts为nil时等同于Begin()。
+//注意：go-sqlite3驱动会忽略隔离级别和只读选项（SQLite的事务总是可串行化的）。
+//如果会话已经处于事务中，则不会开启新事务，而是建立一个保存点（SAVEPOINT），
+//与之配对的Commit()/Rollback()只释放或回滚到这个保存点，此时opts被忽略。
+func (s *Session) BeginTx(opts *TxOptions) (err error) {
+	if s.tx != nil {
+		return s.savepoint()
+	}
+	s.Logger().Debugf(s.Context(), "transaction begin")
+	var txOpts *sql.TxOptions
+	if opts != nil {
+		txOpts = &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly}
+	}
+	if s.conn != nil {
+		s.tx, err = s.conn.BeginTx(s.Context(), txOpts)
+	} else {
+		s.tx, err = s.db.BeginTx(s.Context(), txOpts)
+	}
+	if err != nil {
+		s.Logger().Errorf(s.Context(), "%v", err)
+		return
+	}
+	s.txDepth = 1
+	if opts == nil || opts.Mode == "" {
 		return
 	}
+	if err = s.setTxMode(opts.Mode); err != nil {
+		//方言可能已经结束了驱动的事务（如SQLite先ROLLBACK再BEGIN IMMEDIATE，BEGIN因为拿不到锁失败），
+		//这时连接上没有事务，*sql.Tx不能再用，否则之后的语句会在连接上自动提交。
+		//回滚它让database/sql收回连接，会话回到没有事务的状态，由调用者（或重试策略）重新开始
+		err = fmt.Errorf("begin %s transaction: %w", opts.Mode, err)
+		s.Logger().Errorf(s.Context(), "%v", err)
+		_ = s.tx.Rollback()
+		s.tx = nil
+		s.txDepth = 0
+	}
 	return
 }
 
+//由方言设置事务的加锁方式
+func (s *Session) setTxMode(mode string) error {
+	d, ok := s.dialectSQL.(dialect.TxModeDialect)
+	if !ok {
+		return fmt.Errorf("%w: transaction mode %s", dialect.ErrNotSupported, mode)
+	}
+	stmts, err := d.TxModeSQL(mode)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range stmts {
+		if _, err = s.tx.ExecContext(s.Context(), stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *Session) Commit() (err error) {
-	log.Info("transaction commit")
+	if s.tx == nil {
+		return ErrNoTransaction
+	}
+	if s.txDepth > 1 {
+		return s.releaseSavepoint()
+	}
+	s.Logger().Debugf(s.Context(), "transaction commit")
 	if err = s.tx.Commit(); err != nil {
-		log.Error(err)
+		s.Logger().Errorf(s.Context(), "%v", err)
 	}
 	s.tx=nil //注意Commit()或Rollback()需要将s.tx设置为空
+	s.txDepth = 0
 	return
 }
 
 func (s *Session) Rollback() (err error) {
-	log.Info("transaction rollback")
+	if s.tx == nil {
+		return ErrNoTransaction
+	}
+	if s.txDepth > 1 {
+		return s.rollbackToSavepoint()
+	}
+	s.Logger().Debugf(s.Context(), "transaction rollback")
 	if err = s.tx.Rollback(); err != nil {
-		log.Error(err)
+		s.Logger().Errorf(s.Context(), "%v", err)
 	}
 	s.tx=nil
+	s.txDepth = 0
 	return
 }
 
+//嵌套事务：第n层（n>1）事务对应名为sp_(n-1)的保存点。
+//内层事务失败只回滚到自己的保存点，外层事务开始以来、保存点之前的操作不受影响。
+func savepointName(depth int) string {
+	return fmt.Sprintf("sp_%d", depth)
+}
+
+func (s *Session) savepoint() error {
+	if !s.dialectSQL.Capabilities().Savepoints {
+		return fmt.Errorf("%w: nested transaction (savepoints)", dialect.ErrNotSupported)
+	}
+	name := savepointName(s.txDepth)
+	s.Logger().Debugf(s.Context(), "transaction savepoint %s", name)
+	if _, err := s.tx.ExecContext(s.Context(), "SAVEPOINT "+name); err != nil {
+		s.Logger().Errorf(s.Context(), "%v", err)
+		return err
+	}
+	s.txDepth++
+	return nil
+}
+
+func (s *Session) releaseSavepoint() error {
+	name := savepointName(s.txDepth - 1)
+	s.Logger().Debugf(s.Context(), "transaction release %s", name)
+	if _, err := s.tx.ExecContext(s.Context(), "RELEASE SAVEPOINT "+name); err != nil {
+		s.Logger().Errorf(s.Context(), "%v", err)
+		return err
+	}
+	s.txDepth--
+	return nil
+}
+
+//ROLLBACK TO 之后保存点仍然存在，需要再RELEASE一次。
+//与releaseSavepoint()一样，两条语句都成功后才退出这一层，失败时保存点和层数都不变
+func (s *Session) rollbackToSavepoint() error {
+	name := savepointName(s.txDepth - 1)
+	s.Logger().Debugf(s.Context(), "transaction rollback to %s", name)
+	if _, err := s.tx.ExecContext(s.Context(), "ROLLBACK TO SAVEPOINT "+name); err != nil {
+		s.Logger().Errorf(s.Context(), "%v", err)
+		return err
+	}
+	if _, err := s.tx.ExecContext(s.Context(), "RELEASE SAVEPOINT "+name); err != nil {
+		s.Logger().Errorf(s.Context(), "%v", err)
+		return err
+	}
+	s.txDepth--
+	return nil
+}
+
+//事务的嵌套层数，0表示不在事务中
+func (s *Session) TxDepth() int {
+	return s.txDepth
+}
+
 /*
 Session.DB()中，如果s.tx非空则返回s.tx。
 而Exec()是执行Session.DB()返回的函数。
@@ -0,0 +1,161 @@
+package session
+
+import (
+	"database/sql"
+	"errors"
+	"myorm/dialect"
+	"path/filepath"
+	"testing"
+)
+
+//两个会话共用一个文件数据库，各自使用不同的连接
+func fileSessions(t *testing.T) (*Session, *Session) {
+	source := filepath.Join(t.TempDir(), "tx.db") + "?_busy_timeout=0"
+	db, err := sql.Open("sqlite3", source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	dial, _ := dialect.GetDialect("sqlite3")
+	s1, s2 := New(db, dial).Model(&Account{}), New(db, dial).Model(&Account{})
+	if err := s1.CreateTable(); err != nil {
+		t.Fatal(err)
+	}
+	return s1, s2
+}
+
+func TestSession_BeginTxMode(t *testing.T) {
+	s1, s2 := fileSessions(t)
+	if err := s1.BeginTx(&TxOptions{Mode: "IMMEDIATE"}); err != nil {
+		t.Fatal(err)
+	}
+	//IMMEDIATE事务一开始就持有写锁，其他连接不能写入
+	if _, err := s2.Insert(&Account{ID: 1}); err == nil {
+		t.Fatal("expected database is locked")
+	}
+	if _, err := s1.Insert(&Account{ID: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s1.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := s2.Count(); n != 1 {
+		t.Fatal("failed to commit immediate transaction")
+	}
+
+	//DEFERRED事务在第一次写入前不持有锁
+	if err := s1.BeginTx(&TxOptions{Mode: "DEFERRED"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s2.Insert(&Account{ID: 3}); err != nil {
+		t.Fatal(err)
+	}
+	_ = s1.Rollback()
+
+	if err := s1.BeginTx(&TxOptions{Mode: "SHARED"}); err == nil || s1.tx != nil {
+		t.Fatal("expected invalid transaction mode")
+	}
+}
+
+func TestSession_BeginTxModeBusy(t *testing.T) {
+	s1, s2 := fileSessions(t)
+	if err := s2.BeginTx(&TxOptions{Mode: "IMMEDIATE"}); err != nil {
+		t.Fatal(err)
+	}
+	//拿不到写锁时BEGIN IMMEDIATE失败，会话不能留下一个没有事务的*sql.Tx
+	err := s1.BeginTx(&TxOptions{Mode: "IMMEDIATE"})
+	if err == nil || s1.tx != nil || !s1.dialectSQL.(dialect.RetryableDialect).IsRetryable(err) {
+		t.Fatal("expected a retryable error without a transaction", err)
+	}
+	if err := s1.Commit(); !errors.Is(err, ErrNoTransaction) {
+		t.Fatal("expected ErrNoTransaction, got", err)
+	}
+	if err := s2.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	//连接回到了连接池，可以重新开始事务
+	if err := s1.BeginTx(&TxOptions{Mode: "IMMEDIATE"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s1.Insert(&Account{ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	_ = s1.Rollback()
+	if n, _ := s2.Count(); n != 0 {
+		t.Fatal("rolled back insert was committed", n)
+	}
+}
+
+func TestSession_NestedTransaction(t *testing.T) {
+	s := accountSession(t)
+	_, _, err := s.Transaction(func(s *Session) (*Session, interface{}, error) {
+		_, _ = s.Insert(&Account{ID: 2})
+		//内层事务失败，只回滚它自己插入的记录
+		_, _, err := s.Transaction(func(s *Session) (*Session, interface{}, error) {
+			_, _ = s.Insert(&Account{ID: 3})
+			return s, nil, errors.New("inner failed")
+		})
+		if err == nil || s.TxDepth() != 1 {
+			t.Fatal("expected inner transaction to fail")
+		}
+		_, _, err = s.Transaction(func(s *Session) (*Session, interface{}, error) {
+			_, err := s.Insert(&Account{ID: 4})
+			return s, nil, err
+		})
+		return s, nil, err
+	})
+	if err != nil || s.TxDepth() != 0 {
+		t.Fatal("failed to commit outer transaction", err)
+	}
+	var accounts []Account
+	_ = s.OrderBy("ID").Find(&accounts)
+	if len(accounts) != 3 || accounts[1].ID != 2 || accounts[2].ID != 4 {
+		t.Fatal("failed to roll back to savepoint", accounts)
+	}
+	if err := s.Commit(); err != ErrNoTransaction {
+		t.Fatal("expected ErrNoTransaction, got", err)
+	}
+}
+
+func TestSession_RollbackToSavepointFailed(t *testing.T) {
+	s := accountSession(t)
+	if err := s.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Begin(); err != nil || s.TxDepth() != 2 {
+		t.Fatal("failed to create savepoint", err)
+	}
+	//保存点被释放后ROLLBACK TO失败，会话仍然处于内层
+	if _, err := s.Raw("RELEASE SAVEPOINT " + savepointName(1)).Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Rollback(); err == nil || s.TxDepth() != 2 {
+		t.Fatal("failed rollback to savepoint should keep the depth", err, s.TxDepth())
+	}
+	if _, err := s.Raw("SAVEPOINT " + savepointName(1)).Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Rollback(); err != nil || s.TxDepth() != 1 {
+		t.Fatal("failed to roll back to savepoint", err)
+	}
+	if err := s.Rollback(); err != nil || s.TxDepth() != 0 {
+		t.Fatal("failed to roll back transaction", err)
+	}
+}
+
+//不支持保存点的方言
+type noSavepoints struct{ dialect.Dialect }
+
+func (noSavepoints) Capabilities() dialect.Capabilities { return dialect.Capabilities{} }
+
+func TestSession_SavepointNotSupported(t *testing.T) {
+	s := NewSession(t)
+	s.dialectSQL = noSavepoints{s.dialectSQL}
+	if err := s.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Rollback()
+	if err := s.Begin(); !errors.Is(err, dialect.ErrNotSupported) || s.TxDepth() != 1 {
+		t.Fatal("expected ErrNotSupported, got", err)
+	}
+}